@@ -6,4 +6,5 @@ require (
 	github.com/gdamore/tcell v1.3.0
 	github.com/jlaffaye/ftp v0.0.0-20200720194710-13949d38913e
 	github.com/rivo/tview v0.0.0-20200712113419-c65badfc3d92
+	golang.org/x/text v0.3.2
 )
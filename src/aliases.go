@@ -0,0 +1,51 @@
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// Reads "Alias;Stop name" lines, one alias can stand for many stops when
+// it's given on more than one line
+func ReadAliases(path string) (aliases map[string][]string, e error) {
+	aliases = make(map[string][]string)
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return aliases, nil
+	} else if err != nil {
+		return aliases, err
+	}
+
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		if len(parts) != 2 || len(strings.TrimSpace(parts[0])) == 0 {
+			e = fmt.Errorf("%s:%d: expected \"Alias;Stop name\"", path, i + 1)
+			continue
+		}
+
+		alias := strings.TrimSpace(parts[0])
+		aliases[alias] = append(aliases[alias], strings.TrimSpace(parts[1]))
+	}
+
+	return
+}
+
+// The user's aliases on top of the ones from the database
+func MergeAliases(shipped, user map[string][]string) map[string][]string {
+	result := make(map[string][]string)
+	for _, aliases := range []map[string][]string { shipped, user } {
+		for alias, names := range aliases {
+			result[alias] = append(result[alias], names...)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,377 @@
+package scheduler
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+type Command struct {
+	Name string
+	Usage string
+	Run func(database *Database, args []string) error
+}
+
+// Commands that print their answer to the terminal instead of starting the interface
+var commands []Command
+
+func init() {
+	commands = []Command {
+		{
+			Name: "trips",
+			Usage: "report departures that couldn't be linked into trips",
+			Run: CommandTrips,
+		},
+		{
+			Name: "stats",
+			Usage: "<line> [stop name], first/last departures and headways of a line",
+			Run: CommandStats,
+		},
+		{
+			Name: "transfers",
+			Usage: "[stop name], footpaths and transfer times from the stop",
+			Run: CommandTransfers,
+		},
+		{
+			Name: "reach",
+			Usage: "[-minutes 30] [-format table|csv|json] [-only lines] [-except lines] <from>, stops reachable from now on",
+			Run: CommandReach,
+		},
+		{
+			Name: "plan",
+			Usage: "[-via stop] [-avoid stop] [-only lines] [-except lines] [-low-floor] <from> <to>, journeys with transfers leaving from now on",
+			Run: CommandPlan,
+		},
+		{
+			Name: "modes",
+			Usage: "transport mode of every line",
+			Run: CommandModes,
+		},
+		{
+			Name: "favourites",
+			Usage: "saved stops, connections and lines with their next departures",
+			Run: CommandFavourites,
+		},
+	}
+}
+
+// Adds -only and -except, both taking lists like "tram, 139"
+func LineFilterFlags(flags *flag.FlagSet) func() (LineFilter, error) {
+	only := flags.String("only", "", "use only these lines and modes (tram, bus, night, agglomeration)")
+	except := flags.String("except", "", "don't use these lines and modes")
+	return func() (LineFilter, error) {
+		return ParseLineFilter(*only, *except)
+	}
+}
+
+func RunCommand(database *Database, args []string) error {
+	for _, command := range commands {
+		if command.Name == args[0] {
+			return command.Run(database, args[1:])
+		}
+	}
+
+	PrintUsage(os.Stderr)
+	return fmt.Errorf("Unknown command \"%s\"", args[0])
+}
+
+func PrintUsage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: scheduler [flags] [command]")
+	fmt.Fprintln(w, "\nWithout a command the interactive interface is started. Commands:")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, command := range commands {
+		fmt.Fprintf(tw, "  %s\t%s\n", command.Name, command.Usage)
+	}
+	tw.Flush()
+}
+
+func FormatDeparture(dep Departure) string {
+	return fmt.Sprintf("%02d:%02d%s", dep.Hour, dep.Minute, dep.Annotation)
+}
+
+func CommandTrips(database *Database, args []string) error {
+	report := database.TripReport
+
+	fmt.Printf("Reconstructed %d trips\n", report.Trips)
+	for kind := TripEndsEarly; kind <= TripAmbiguous; kind++ {
+		fmt.Printf("  %s: %d\n", kind, report.Count(kind))
+	}
+
+	if len(report.Issues) == 0 {
+		return nil
+	}
+
+	fmt.Println()
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Issue\tLine\tDirection\tStop name\tDay\tDeparture")
+	for _, issue := range report.Issues {
+		stop := issue.Stop
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\t%s\t%s\n", issue.Kind, stop.LineNr,
+			stop.Direction, stop.Name, issue.Day, FormatDeparture(issue.Departure))
+	}
+
+	return tw.Flush()
+}
+
+func WriteStats(w io.Writer, stats [DayTypeCount]HeadwayStats) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "  " + strings.Replace(StatsHeaders, ";", "\t", -1))
+	for day := WorkDay; day < DayTypeCount; day++ {
+		fmt.Fprintf(tw, "  %s\t%s\n", day, strings.Join(stats[day].Columns(), "\t"))
+	}
+
+	fmt.Fprintln(tw, "\n  Departures per hour")
+	fmt.Fprint(tw, "  Hour")
+	for h := ServiceDayStartHour; h < ServiceDayStartHour + 24; h++ {
+		fmt.Fprintf(tw, "\t%d", h % 24)
+	}
+	fmt.Fprintln(tw)
+
+	for day := WorkDay; day < DayTypeCount; day++ {
+		fmt.Fprintf(tw, "  %s", day)
+		for h := ServiceDayStartHour; h < ServiceDayStartHour + 24; h++ {
+			fmt.Fprintf(tw, "\t%d", stats[day].PerHour[h % 24])
+		}
+		fmt.Fprintln(tw)
+	}
+
+	return tw.Flush()
+}
+
+func CommandStats(database *Database, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("Usage: scheduler stats <line> [stop name]")
+	}
+
+	lineNr, err := strconv.Atoi(args[0])
+	if err != nil {
+		return fmt.Errorf("\"%s\" is not a line number", args[0])
+	}
+
+	routes := StatsForLine(database.Stops, lineNr)
+	if len(routes) == 0 {
+		return fmt.Errorf("There is no line %d in the database", lineNr)
+	}
+
+	stopName := strings.Join(args[1:], " ")
+	if len(stopName) == 0 {
+		for _, route := range routes {
+			fmt.Printf("Line %d towards %s, from %s\n", route.LineNr, route.Direction, route.FirstStop.Name)
+			if err := WriteStats(os.Stdout, route.Stats); err != nil {
+				return err
+			}
+			fmt.Println()
+		}
+
+		return nil
+	}
+
+	found := false
+	for _, stop := range database.Stops {
+		if stop.LineNr != lineNr || !InputFilter(stop.Name, stopName) {
+			continue
+		}
+
+		var stats [DayTypeCount]HeadwayStats
+		for day := WorkDay; day < DayTypeCount; day++ {
+			stats[day] = StatsForStop(stop, day)
+		}
+
+		found = true
+		fmt.Printf("Line %d towards %s, at %s\n", stop.LineNr, stop.Direction, stop.Name)
+		if err := WriteStats(os.Stdout, stats); err != nil {
+			return err
+		}
+		fmt.Println()
+	}
+
+	if !found {
+		return fmt.Errorf("Line %d doesn't stop at \"%s\"", lineNr, stopName)
+	}
+
+	return nil
+}
+
+func WriteJourney(w io.Writer, journey Journey) error {
+	now := Now()
+	first, last := journey.First(), journey.Last()
+	fmt.Fprintf(w, "%s -> %s, %d min, %s\n", FormatDeparture(first.Departure),
+		FormatDeparture(last.Arrival), journey.Duration(now), journey.Path())
+	if len(journey.Constraints) != 0 {
+		fmt.Fprintf(w, "  %s\n", journey.Constraints)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, leg := range journey.Legs {
+		if leg.IsWalk() {
+			fmt.Fprintf(tw, "  \twalk %d min\t%s %s\t->\t%s %s\n", leg.Minutes(),
+				FormatDeparture(leg.Departure), leg.From.Name,
+				FormatDeparture(leg.Arrival), leg.To.Name)
+			continue
+		}
+
+		fmt.Fprintf(tw, "  wait %d min\t%d towards %s\t%s %s\t->\t%s %s\n", leg.Wait,
+			leg.Trip.LineNr, leg.Trip.Direction,
+			FormatDeparture(leg.Departure), leg.From.Name,
+			FormatDeparture(leg.Arrival), leg.To.Name)
+	}
+
+	return tw.Flush()
+}
+
+func CommandPlan(database *Database, args []string) error {
+	flags := flag.NewFlagSet("plan", flag.ContinueOnError)
+	via := flags.String("via", "", "stop the journey has to pass through")
+	avoid := flags.String("avoid", "", "stop the journey must not touch")
+	lowFloor := flags.Bool("low-floor", false, "ride only vehicles marked as low-floor")
+	lineFilter := LineFilterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	lines, err := lineFilter()
+	if err != nil {
+		return err
+	}
+
+	args = flags.Args()
+	if len(args) != 2 {
+		return fmt.Errorf("Usage: scheduler plan [-via stop] [-avoid stop] [-only lines] [-except lines] [-low-floor] <from> <to>, quote names with spaces")
+	}
+
+	constraints := NewConstraints(*via, *avoid)
+	constraints.Lines = lines
+	constraints.LowFloor = *lowFloor
+	journeys := PlanJourneys(database, args[0], args[1], Now(), constraints)
+	if len(journeys) == 0 {
+		return fmt.Errorf("No journey from \"%s\" to \"%s\" with at most %d transfers",
+			args[0], args[1], MaxTransfers)
+	}
+
+	for i, journey := range journeys {
+		if i != 0 {
+			fmt.Println()
+		}
+		if err := WriteJourney(os.Stdout, journey); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func CommandTransfers(database *Database, args []string) error {
+	if database.TransfersError != nil {
+		fmt.Fprintln(os.Stderr, database.TransfersError)
+	}
+
+	tt := database.Timetable(WorkDay)
+	name := strings.Join(args, " ")
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Stop name\tChange\tWalk to")
+	for place, placeName := range tt.Places {
+		if len(name) != 0 && !InputFilter(placeName, name) {
+			continue
+		} else if len(name) == 0 && len(tt.Footpaths[place]) == 0 &&
+			tt.TransferTime[place] == MinTransferTime {
+			continue
+		}
+
+		var walks []string
+		for _, path := range tt.Footpaths[place] {
+			walks = append(walks, fmt.Sprintf("%s (%d min)", tt.Places[path.To], path.Minutes))
+		}
+
+		fmt.Fprintf(tw, "%s\t%d min\t%s\n", placeName, tt.TransferTime[place], strings.Join(walks, ", "))
+	}
+
+	return tw.Flush()
+}
+
+func CommandReach(database *Database, args []string) error {
+	flags := flag.NewFlagSet("reach", flag.ContinueOnError)
+	minutes := flags.Int("minutes", 30, "time budget of the journey")
+	format := flags.String("format", "table", "output as a table, csv or json")
+	lineFilter := LineFilterFlags(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	lines, err := lineFilter()
+	if err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 {
+		return fmt.Errorf("Usage: scheduler reach [-minutes 30] [-format table|csv|json] [-only lines] [-except lines] <from>")
+	}
+
+	from := strings.Join(flags.Args(), " ")
+	reachable := ReachableStops(database, from, Now(), *minutes, lines)
+
+	switch *format {
+	case "csv":
+		return WriteReachableCSV(os.Stdout, reachable)
+	case "json":
+		return WriteReachableJSON(os.Stdout, reachable)
+	case "table":
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Replace(ReachableHeaders, ";", "\t", -1))
+		for _, r := range reachable {
+			fmt.Fprintln(tw, strings.Join(r.Columns(), "\t"))
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("Unknown format \"%s\", expected table, csv or json", *format)
+	}
+}
+
+func CommandModes(database *Database, args []string) error {
+	if database.ModesError != nil {
+		fmt.Fprintln(os.Stderr, database.ModesError)
+	}
+
+	var lines [ModeCount][]string
+	for i, stop := range database.Stops {
+		if i != 0 && database.Stops[i - 1].LineNr == stop.LineNr {
+			continue
+		}
+
+		mode := ModeOf(stop.LineNr)
+		number := strconv.Itoa(stop.LineNr)
+		if n := len(lines[mode]); n == 0 || lines[mode][n - 1] != number {
+			lines[mode] = append(lines[mode], number)
+		}
+	}
+
+	for mode := Tram; mode < ModeCount; mode++ {
+		fmt.Printf("%s: %s\n", mode, strings.Join(lines[mode], ", "))
+	}
+
+	return nil
+}
+
+func CommandFavourites(database *Database, args []string) error {
+	favourites, err := ReadFavourites(CreateConfigPath("favourites"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+
+	if len(favourites) == 0 {
+		fmt.Println("No favourites, save them with Ctrl+B or Ctrl+S in the search table")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "Kind\tFavourite\tNext departure")
+	for _, favourite := range favourites {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", favourite.Kind, favourite, favourite.NextDeparture(database))
+	}
+
+	return tw.Flush()
+}
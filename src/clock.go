@@ -0,0 +1,86 @@
+package scheduler
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Source of "now" for every departure calculation. Swapping it lets the UI
+// show the schedule as of any moment and makes the calculations deterministic.
+type Clock interface {
+	Now() time.Time
+}
+
+type SystemClock struct {}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// Always reports the same moment
+type FixedClock struct {
+	Time time.Time
+}
+
+func (c FixedClock) Now() time.Time {
+	return c.Time
+}
+
+// Keeps ticking, but shifted by `Offset` from the real time. That's what the
+// "time travel" uses, so countdowns still move while looking at another moment.
+type OffsetClock struct {
+	Offset time.Duration
+}
+
+func (c OffsetClock) Now() time.Time {
+	return time.Now().Add(c.Offset)
+}
+
+func NewOffsetClock(at time.Time) OffsetClock {
+	return OffsetClock {
+		Offset: at.Sub(time.Now()),
+	}
+}
+
+var (
+	clock Clock = SystemClock{}
+	// The time travel swaps the clock while the tickers read it
+	clockMutex sync.RWMutex
+)
+
+func SetClock(c Clock) {
+	clockMutex.Lock()
+	defer clockMutex.Unlock()
+	clock = c
+}
+
+func CurrentClock() Clock {
+	clockMutex.RLock()
+	defer clockMutex.RUnlock()
+	return clock
+}
+
+func IsTimeTravelling() bool {
+	_, real := CurrentClock().(SystemClock)
+	return !real
+}
+
+const TimeTravelLayout = "2006-01-02 15:04"
+
+// Parses a moment written in the schedule's time zone, either a full
+// date with time or just the time for today
+func ParseScheduleTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.ParseInLocation(TimeTravelLayout, value, ScheduleLocation()); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation("15:04", value, ScheduleLocation())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("Expected \"%s\" or \"15:04\", got \"%s\"", TimeTravelLayout, value)
+	}
+
+	return DepartureTime(Now(), t.Hour(), t.Minute()), nil
+}
@@ -0,0 +1,230 @@
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+type FavouriteKind int
+
+const (
+	FavouriteStop FavouriteKind = iota
+	FavouriteConnection
+	FavouriteLine
+)
+
+func (kind FavouriteKind) String() string {
+	switch kind {
+	case FavouriteConnection:
+		return "connection"
+	case FavouriteLine:
+		return "line"
+	default:
+		return "stop"
+	}
+}
+
+// A stop, a ride between two stops or a line going in a direction from a stop,
+// all of them by their exact names
+type Favourite struct {
+	Kind FavouriteKind
+	// The stop of a stop or a line, where a connection starts
+	Stop string
+	// Where a connection ends
+	To string
+	LineNr int
+	Direction string
+}
+
+func (favourite Favourite) String() string {
+	switch favourite.Kind {
+	case FavouriteConnection:
+		return favourite.Stop + " -> " + favourite.To
+	case FavouriteLine:
+		return fmt.Sprintf("%d towards %s at %s", favourite.LineNr, favourite.Direction, favourite.Stop)
+	default:
+		return favourite.Stop
+	}
+}
+
+// The line of the favourites file
+func (favourite Favourite) Record() string {
+	switch favourite.Kind {
+	case FavouriteConnection:
+		return strings.Join([]string { "connection", favourite.Stop, favourite.To }, ";")
+	case FavouriteLine:
+		return strings.Join([]string { "line", strconv.Itoa(favourite.LineNr),
+			favourite.Direction, favourite.Stop }, ";")
+	default:
+		return strings.Join([]string { "stop", favourite.Stop }, ";")
+	}
+}
+
+func ParseFavourite(record string) (favourite Favourite, e error) {
+	parts := strings.Split(record, ";")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+		if len(parts[i]) == 0 {
+			return favourite, fmt.Errorf("empty field %d", i + 1)
+		}
+	}
+
+	switch {
+	case parts[0] == "stop" && len(parts) == 2:
+		return Favourite { Kind: FavouriteStop, Stop: parts[1] }, nil
+	case parts[0] == "connection" && len(parts) == 3:
+		return Favourite { Kind: FavouriteConnection, Stop: parts[1], To: parts[2] }, nil
+	case parts[0] == "line" && len(parts) == 4:
+		lineNr, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return favourite, fmt.Errorf("\"%s\" is not a line number", parts[1])
+		}
+
+		return Favourite { Kind: FavouriteLine, LineNr: lineNr, Direction: parts[2], Stop: parts[3] }, nil
+	}
+
+	return favourite, fmt.Errorf("expected \"stop;Stop\", \"connection;From;To\" or \"line;52;Direction;Stop\"")
+}
+
+// Every bad line of a file, one per line
+type LineErrors []error
+
+func (errs LineErrors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// The first of them, for where there is room for one line
+func (errs LineErrors) Short() string {
+	switch len(errs) {
+	case 1:
+		return errs[0].Error()
+	case 2:
+		return fmt.Sprintf("%v and 1 more bad line", errs[0])
+	default:
+		return fmt.Sprintf("%v and %d more bad lines", errs[0], len(errs) - 1)
+	}
+}
+
+// The favourites of the good lines, the error lists the bad ones
+func ReadFavourites(path string) (favourites []Favourite, e error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var errs LineErrors
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		favourite, err := ParseFavourite(line)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s:%d: %v", path, i + 1, err))
+			continue
+		}
+
+		favourites = append(favourites, favourite)
+	}
+
+	if len(errs) != 0 {
+		return favourites, errs
+	}
+	return favourites, nil
+}
+
+func WriteFavourites(path string, favourites []Favourite) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("# stop;Stop, connection;From;To or line;52;Direction;Stop\n")
+	for _, favourite := range favourites {
+		b.WriteString(favourite.Record() + "\n")
+	}
+
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// Adds the favourite unless it's already there
+func AddFavourite(favourites []Favourite, favourite Favourite) ([]Favourite, bool) {
+	for _, f := range favourites {
+		if f == favourite {
+			return favourites, false
+		}
+	}
+
+	return append(favourites, favourite), true
+}
+
+// The ride or the line of the row, `stopOnly` keeps just the stop it leaves from
+func FavouriteFromConnection(connection Connection, stopOnly bool) Favourite {
+	switch {
+	case stopOnly:
+		return Favourite { Kind: FavouriteStop, Stop: connection.Stop.Name }
+	case connection.Journey != nil:
+		return Favourite { Kind: FavouriteConnection,
+			Stop: connection.Journey.First().From.Name, To: connection.Journey.Last().To.Name }
+	case len(connection.Ride) != 0:
+		return Favourite { Kind: FavouriteConnection,
+			Stop: connection.Ride[0].Name, To: connection.Ride[len(connection.Ride) - 1].Name }
+	default:
+		return Favourite { Kind: FavouriteLine, LineNr: connection.Stop.LineNr,
+			Direction: connection.Stop.Direction, Stop: connection.Stop.Name }
+	}
+}
+
+// When the favourite is next served, counted from now
+func (favourite Favourite) NextDeparture(database *Database) string {
+	switch favourite.Kind {
+	case FavouriteConnection:
+		constraints := NewConstraints("", "")
+		journeys := PlanJourneys(database, PinName(favourite.Stop), PinName(favourite.To), Now(), constraints)
+		if len(journeys) == 0 {
+			return "No journey found"
+		}
+
+		return InfoNextJourney(SortJourneys(journeys, ByDeparture)[0])
+	case FavouriteLine:
+		for _, stop := range database.Stops {
+			if stop.LineNr == favourite.LineNr && stop.Direction == favourite.Direction &&
+				stop.Name == favourite.Stop {
+				return InfoNextBus(stop, false)
+			}
+		}
+
+		return "Not in the database"
+	default:
+		var next *Stop
+		soonest := -1
+		for i, stop := range database.Stops {
+			if stop.Name != favourite.Stop {
+				continue
+			}
+
+			if minutes := MinsToNextBus(stop, false); next == nil ||
+				(minutes >= 0 && (soonest < 0 || minutes < soonest)) {
+				next, soonest = &database.Stops[i], minutes
+			}
+		}
+
+		if next == nil {
+			return "Not in the database"
+		}
+
+		return fmt.Sprintf("%d towards %s, %s", next.LineNr, next.Direction, InfoNextBus(*next, false))
+	}
+}
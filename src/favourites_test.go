@@ -0,0 +1,153 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFavouriteRecord(t *testing.T) {
+	favourites := []Favourite {
+		{ Kind: FavouriteStop, Stop: "Teatr Bagatela" },
+		{ Kind: FavouriteConnection, Stop: "Wawel", To: "Salwator" },
+		{ Kind: FavouriteLine, LineNr: 52, Direction: "Czyżyny", Stop: "Rondo Mogilskie" },
+	}
+
+	for _, favourite := range favourites {
+		parsed, err := ParseFavourite(favourite.Record())
+		if err != nil || parsed != favourite {
+			t.Errorf("ParseFavourite(%q) = %+v, %v, want %+v", favourite.Record(), parsed, err, favourite)
+		}
+	}
+
+	parsed, err := ParseFavourite(" line ; 52 ;Czyżyny; Rondo Mogilskie ")
+	if err != nil || parsed != favourites[2] {
+		t.Errorf("Expected the spaces around fields to be ignored, got %+v, %v", parsed, err)
+	}
+
+	bad := []string {
+		"",
+		"stop",
+		"stop;",
+		"stop;A;B",
+		"connection;Wawel",
+		"connection;Wawel;",
+		"line;5x;Czyżyny;Rondo Mogilskie",
+		"line;52;Czyżyny",
+		"tram;Wawel",
+		"Stop;Wawel",
+	}
+	for _, record := range bad {
+		if favourite, err := ParseFavourite(record); err == nil {
+			t.Errorf("ParseFavourite(%q) = %+v, expected an error", record, favourite)
+		}
+	}
+}
+
+func TestReadFavourites(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "favourites")
+	if favourites, err := ReadFavourites(path); favourites != nil || err != nil {
+		t.Fatalf("Expected nothing from a missing file, got %v, %v", favourites, err)
+	}
+
+	content := strings.Join([]string {
+		"# stop;Stop",
+		"stop;Wawel",
+		"bus;Wawel",
+		"",
+		"connection;Wawel;Salwator",
+		"line;x;Czyżyny;Rondo Mogilskie",
+	}, "\n")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	favourites, err := ReadFavourites(path)
+	expected := []Favourite {
+		{ Kind: FavouriteStop, Stop: "Wawel" },
+		{ Kind: FavouriteConnection, Stop: "Wawel", To: "Salwator" },
+	}
+	if !reflect.DeepEqual(favourites, expected) {
+		t.Errorf("Expected %v, got %v", expected, favourites)
+	}
+
+	errs, ok := err.(LineErrors)
+	if !ok || len(errs) != 2 {
+		t.Fatalf("Expected the 2 bad lines, got %v", err)
+	}
+	if !strings.HasPrefix(errs[0].Error(), path + ":3:") || !strings.HasPrefix(errs[1].Error(), path + ":6:") {
+		t.Errorf("Expected lines 3 and 6, got %v", errs)
+	}
+	if !strings.HasSuffix(errs.Short(), "and 1 more bad line") {
+		t.Errorf("Expected the first error and how many more, got %q", errs.Short())
+	}
+
+	// Written and read back
+	if err := WriteFavourites(path, favourites); err != nil {
+		t.Fatal(err)
+	}
+	if read, err := ReadFavourites(path); err != nil || !reflect.DeepEqual(read, favourites) {
+		t.Errorf("Expected %v written and read back, got %v, %v", favourites, read, err)
+	}
+}
+
+func TestAddFavourite(t *testing.T) {
+	wawel := Favourite { Kind: FavouriteStop, Stop: "Wawel" }
+
+	favourites, added := AddFavourite(nil, wawel)
+	if !added || len(favourites) != 1 {
+		t.Fatalf("Expected the favourite to be added, got %v, %v", favourites, added)
+	}
+
+	if favourites, added = AddFavourite(favourites, wawel); added || len(favourites) != 1 {
+		t.Errorf("Expected the same favourite to be added once, got %v, %v", favourites, added)
+	}
+
+	line := Favourite { Kind: FavouriteLine, LineNr: 52, Direction: "Czyżyny", Stop: "Wawel" }
+	if favourites, added = AddFavourite(favourites, line); !added || len(favourites) != 2 {
+		t.Errorf("Expected a line at the same stop to be added, got %v, %v", favourites, added)
+	}
+}
+
+func TestFavouriteFromConnection(t *testing.T) {
+	stop := func(name string) Stop {
+		return Stop { LineNr: 52, Direction: "Czyżyny", Name: name }
+	}
+	wawel, teatr, salwator := stop("Wawel"), stop("Teatr Bagatela"), stop("Salwator")
+
+	journey := &Journey { Legs: []Leg {
+		{ From: wawel, To: teatr },
+		{ From: teatr, To: salwator },
+	} }
+
+	tests := []struct {
+		name string
+		connection Connection
+		stopOnly bool
+		want Favourite
+	}{
+		{ "stop row", Connection { Stop: &wawel }, false,
+			Favourite { Kind: FavouriteLine, LineNr: 52, Direction: "Czyżyny", Stop: "Wawel" } },
+		{ "only the stop", Connection { Stop: &wawel, Journey: journey }, true,
+			Favourite { Kind: FavouriteStop, Stop: "Wawel" } },
+		{ "direct ride", Connection { Stop: &wawel, Ride: []Stop { wawel, teatr, salwator } }, false,
+			Favourite { Kind: FavouriteConnection, Stop: "Wawel", To: "Salwator" } },
+		{ "journey", Connection { Stop: &wawel, Journey: journey }, false,
+			Favourite { Kind: FavouriteConnection, Stop: "Wawel", To: "Salwator" } },
+	}
+
+	for _, test := range tests {
+		if got := FavouriteFromConnection(test.connection, test.stopOnly); got != test.want {
+			t.Errorf("%s: expected %+v, got %+v", test.name, test.want, got)
+		}
+	}
+}
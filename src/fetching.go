@@ -29,6 +29,13 @@ type Stop struct {
 	Direction string `json:"direction"`
 	Name string `json:"stop_name"`
 	Times Times `json:"times"`
+
+	// Optional, when present nearby stops are connected by footpaths
+	Lat float64 `json:"lat,omitempty"`
+	Lon float64 `json:"lon,omitempty"`
+
+	// Filled in after loading by `ReconstructTrips`
+	Trips map[TripKey]*Trip `json:"-"`
 }
 
 type DatabaseStatus int
@@ -39,15 +46,64 @@ const (
 	DatabaseComplete
 )
 
+// The schedules from ZTP are written in Kraków's local time, databases that
+// don't carry their own "timezone" field are assumed to be in it.
+const DefaultTimeZone = "Europe/Warsaw"
+
 type Database struct {
 	Stops []Stop
 	Status DatabaseStatus
+
+	Trips []Trip
+	TripReport TripReport
+	timetables [DayTypeCount]*Timetable
+	// By the day type of the night and of the day following it
+	nightTimetables [DayTypeCount][DayTypeCount]*Timetable
+
+	Transfers []TransferRule
+	TransfersError error
+
+	// Line number ranges of each transport mode shipped with the database
+	ModeRanges []ModeRange
+	ModesError error
+
+	// Other names of the stops, shipped with the database and the user's own
+	Aliases map[string][]string
+	AliasesError error
+
+	TimeZone string
+	Location *time.Location
+	// Set when the zone named by the database couldn't be loaded
+	TimeZoneError error
 }
 
 func NewDatabase() Database {
-	return Database {
+	db := Database {
 		Status: DatabaseNotReady,
 	}
+
+	if err := db.SetTimeZone(DefaultTimeZone); err != nil {
+		// NOTE(radomski): Systems without tzdata installed can't load the zone,
+		// the best we can do is to assume that the user is in the schedule's zone
+		db.TimeZone = time.Local.String()
+		db.Location = time.Local
+		SetScheduleLocation(db.Location)
+	}
+
+	return db
+}
+
+func (db *Database) SetTimeZone(name string) error {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return err
+	}
+
+	db.TimeZone = name
+	db.Location = loc
+	SetScheduleLocation(loc)
+
+	return nil
 }
 
 func CreateDatabasePath() string {
@@ -61,6 +117,30 @@ func CreateDatabasePath() string {
 	}
 }
 
+// Files the user edits by hand live next to each other in the config directory
+func CreateConfigPath(name string) string {
+	path, exists := os.LookupEnv("XDG_CONFIG_HOME")
+	if exists {
+		return path + "/scheduler/" + name
+	} else {
+		home := os.Getenv("HOME")
+
+		return home + "/.config/scheduler/" + name
+	}
+}
+
+// Files written for the user to take elsewhere, like the exported reachable stops
+func CreateDataPath(name string) string {
+	path, exists := os.LookupEnv("XDG_DATA_HOME")
+	if exists {
+		return path + "/scheduler/" + name
+	} else {
+		home := os.Getenv("HOME")
+
+		return home + "/.local/share/scheduler/" + name
+	}
+}
+
 func (db *Database) CreateFromJSON() {
 	dbPath := CreateDatabasePath()
 	
@@ -87,6 +167,12 @@ func (db *Database) CreateFromJSON() {
 	return 
 }
 
+func (db *Database) WaitComplete() {
+	for (db.Status & DatabaseComplete) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+}
+
 func (db *Database) RefreshWithWeb() {
 	dbPath := CreateDatabasePath()
 	
@@ -109,15 +195,88 @@ func (db *Database) RefreshWithWeb() {
 }	
 
 
+// The database is either a bare array of stops or an object carrying the
+// metadata next to the stops, e.g. {"timezone": "Europe/Warsaw", "stops": [...]}.
+// The metadata should come before the stops, so it's known while they stream in.
 func (db *Database) ConcurJSONDec(reader io.Reader) {
 	db.Status = DatabaseDecoding
 	
 	dec := json.NewDecoder(reader)
-	_, err := dec.Token()
+	tok, err := dec.Token()
 	if err != nil {
 		panic(err)
 	}
-		
+
+	if tok == json.Delim('{') {
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				panic(err)
+			}
+
+			switch key {
+			case "timezone":
+				var name string
+				if err := dec.Decode(&name); err != nil {
+					panic(err)
+				}
+				// NOTE(radomski): Same as without tzdata, the zone already set is
+				// the best guess, so it's kept and the user is told about it
+				if err := db.SetTimeZone(name); err != nil {
+					db.TimeZoneError = fmt.Errorf("Can't load the time zone \"%s\" of the database, using %s: %v",
+						name, db.TimeZone, err)
+				}
+			case "low_floor":
+				var markers string
+				if err := dec.Decode(&markers); err != nil {
+					panic(err)
+				}
+				SetLowFloorMarkers(markers)
+			case "aliases":
+				if err := dec.Decode(&db.Aliases); err != nil {
+					panic(err)
+				}
+			case "modes":
+				if err := dec.Decode(&db.ModeRanges); err != nil {
+					panic(err)
+				}
+			case "stops":
+				if _, err := dec.Token(); err != nil {
+					panic(err)
+				}
+				db.decodeStops(dec)
+			default:
+				var skip json.RawMessage
+				if err := dec.Decode(&skip); err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		// Closing brace of the database object
+		if _, err := dec.Token(); err != nil {
+			panic(err)
+		}
+	} else {
+		db.decodeStops(dec)
+	}
+	
+	stops := TimesToOneDay(db.Stops)
+	db.Trips, db.TripReport = ReconstructTrips(stops)
+	db.Transfers, db.TransfersError = ReadTransfers(CreateConfigPath("transfers"))
+
+	var userModes []ModeRange
+	userModes, db.ModesError = ReadModeRanges(CreateConfigPath("modes"))
+	SetModeRanges(userModes, db.ModeRanges)
+	db.Stops = stops
+	userAliases, err := ReadAliases(CreateConfigPath("aliases"))
+	db.AliasesError = err
+	db.Aliases = MergeAliases(db.Aliases, userAliases)
+	SetSearchIndex(NewSearchIndex(stops, db.Aliases))
+	db.Status = DatabaseComplete
+}
+
+func (db *Database) decodeStops(dec *json.Decoder) {
 	for dec.More() {
 		var s Stop
 		err := dec.Decode(&s)
@@ -128,13 +287,10 @@ func (db *Database) ConcurJSONDec(reader io.Reader) {
 		db.Stops = append(db.Stops, s)
 	}
 
-	_, err = dec.Token()
-	if err != nil {
+	// Closing bracket of the stops array
+	if _, err := dec.Token(); err != nil {
 		panic(err)
 	}
-	
-	db.Stops = TimesToOneDay(db.Stops)
-	db.Status = DatabaseComplete
 }
 
 func NewDatabaseFileFromWeb(dbPath string) error {
@@ -179,7 +335,6 @@ func FetchFTP(host, username, password string) (b *ftp.Response, err error) {
 
 	if err := c.Quit(); err != nil {
 		panic(err)
-		return r, err
 	}
 
 	return r, nil
@@ -1,6 +1,11 @@
 package scheduler
 
-import "strings"
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
 
 func Max(a, b int) int {
 	if a > b {
@@ -20,46 +25,22 @@ func Min(a, b int) int {
 
 // Based on
 // https://en.wikipedia.org/wiki/Jaro%E2%80%93Winkler_distance
-func JWDist(str1, str2 string) (weight float64) {
+func JWDist(s1, s2 string) (weight float64) {
+	// NOTE(radomski): Indexing strings goes byte by byte, which compares
+	// the halves of multi-byte letters like "ż" instead of the letters
+	str1, str2 := []rune(s1), []rune(s2)
+
 	// We can't compare empty strings
 	if len(str1) == 0 || len(str2) == 0 {
 		return 0.0
 	}
 
 	// If they are the same, we don't need to compare them
-	if str1 == str2 {
+	if s1 == s2 {
 		return 1.0
 	}
 
-	m := 0
-	checkRange := (Max(len(str1), len(str2)) / 2) - 1
-	str1Matches := make([]bool, len(str1))
-	str2Matches := make([]bool, len(str2))
-
-	// Iterate over chars from the first string
-	for i, _ := range str1 {
-		// Set the boundries on the chars that we are going to iterate over
-		// from the second string
-		low := 0
-		if i > checkRange {
-			low = i - checkRange
-		}
-
-		high := i + checkRange
-		if high > len(str2) {
-			high = len(str2)
-		}
-
-		for j := low; j < high; j++ {
-			if str1Matches[i] == false && str2Matches[j] == false && str1[i] == str2[j] {
-				m += 1
-				str1Matches[i] = true
-				str2Matches[j] = true
-				// TODO(radomski): try to remove it and see what happens
-				break
-			}
-		}
-	}
+	str1Matches, str2Matches, m := jaroMatches(str1, str2)
 
 	// If not a single character matched
 	if m == 0 {
@@ -110,12 +91,80 @@ func JWDist(str1, str2 string) (weight float64) {
 	return
 }
 
-func FuzzyScaleInsens(str1, str2 string) float64 {
-	lower1 := strings.ToLower(str1)
-	lower2 := strings.ToLower(str2)
+// Which chars of both strings are the same and close enough to count for
+// the Jaro similarity, and how many of them there are
+func jaroMatches(str1, str2 []rune) (str1Matches, str2Matches []bool, m int) {
+	checkRange := (Max(len(str1), len(str2)) / 2) - 1
+	str1Matches = make([]bool, len(str1))
+	str2Matches = make([]bool, len(str2))
+
+	// Iterate over chars from the first string
+	for i := range str1 {
+		// Set the boundries on the chars that we are going to iterate over
+		// from the second string
+		low := 0
+		if i > checkRange {
+			low = i - checkRange
+		}
 
-	dist := JWDist(lower1, lower2)
-	return dist
+		high := i + checkRange
+		if high > len(str2) {
+			high = len(str2)
+		}
+
+		for j := low; j < high; j++ {
+			if str1Matches[i] == false && str2Matches[j] == false && str1[i] == str2[j] {
+				m += 1
+				str1Matches[i] = true
+				str2Matches[j] = true
+				// TODO(radomski): try to remove it and see what happens
+				break
+			}
+		}
+	}
+
+	return
+}
+
+// Letters that don't decompose into a base letter and a diacritic
+var foldedLetters = map[rune]rune {
+	'ł': 'l',
+	'Ł': 'L',
+}
+
+// Lowercase and without diacritics, so "Łagiewniki" and "Rżąka" can be
+// typed as "lagiewniki" and "rzaka" without a Polish keyboard
+func Normalize(str string) string {
+	var b strings.Builder
+	for _, r := range norm.NFD.String(str) {
+		if unicode.Is(unicode.Mn, r) {
+			continue
+		}
+		if folded, present := foldedLetters[r]; present {
+			r = folded
+		}
+
+		b.WriteRune(unicode.ToLower(r))
+	}
+
+	return norm.NFC.String(b.String())
+}
+
+// The normalized letters of the string, each with the index of the letter
+// of the string it came from
+func normalizeRunes(str string) (runes []rune, origin []int) {
+	for i, r := range []rune(str) {
+		for _, n := range Normalize(string(r)) {
+			runes = append(runes, n)
+			origin = append(origin, i)
+		}
+	}
+
+	return
+}
+
+func FuzzyScaleInsens(str1, str2 string) float64 {
+	return JWDist(Normalize(str1), Normalize(str2))
 }
 
 func IsFuzzyEqualInsens(str1, str2 string, treshold float64) bool {
@@ -0,0 +1,77 @@
+package scheduler
+
+import (
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		name, want string
+	}{
+		{ "Łagiewniki", "lagiewniki" },
+		{ "Rżąka", "rzaka" },
+		{ "Czyżyny", "czyzyny" },
+		{ "Góra Borkowska", "gora borkowska" },
+		{ "Dąbie", "dabie" },
+		{ "Ćwiklińskiej", "cwiklinskiej" },
+		{ "Oś. Piastów", "os. piastow" },
+		{ "Nowy Bieżanów P+R", "nowy biezanow p+r" },
+		{ "Św. Wawrzyńca", "sw. wawrzynca" },
+		{ "Kopiec Wandy", "kopiec wandy" },
+		{ "ŁĄŻÓ", "lazo" },
+	}
+
+	for _, test := range tests {
+		if got := Normalize(test.name); got != test.want {
+			t.Errorf("Normalize(%q) = %q, want %q", test.name, got, test.want)
+		}
+
+		// Letters with their marks given apart, as some keyboards and files do
+		decomposed := norm.NFD.String(test.name)
+		if got := Normalize(decomposed); got != test.want {
+			t.Errorf("Normalize(NFD %q) = %q, want %q", decomposed, got, test.want)
+		}
+	}
+}
+
+func TestJWDist(t *testing.T) {
+	tests := []struct {
+		name, query string
+		// The similarity of the normalized ones is at least `min` and below `max`
+		min, max float64
+	}{
+		{ "Łagiewniki", "lagiewniki", 1.0, 1.01 },
+		{ "Rżąka", "rzaka", 1.0, 1.01 },
+		{ "Czyżyny", "CZYZYNY", 1.0, 1.01 },
+		{ "Góra Borkowska", "gora borkowska", 1.0, 1.01 },
+		{ "Łagiewniki", "lagiewnki", 0.9, 1.0 },
+		{ "Czyżyny", "czyzny", 0.9, 1.0 },
+		{ "Teatr Bagatela", "teatr bagatella", 0.9, 1.0 },
+		{ "Dąbie", "dabei", 0.9, 1.0 },
+		{ "Rżąka", "czyzyny", 0.0, 0.9 },
+		{ "Salwator", "wawel", 0.0, 0.9 },
+		{ "Kopiec Wandy", "", 0.0, 0.01 },
+	}
+
+	for _, test := range tests {
+		got := JWDist(Normalize(test.name), Normalize(test.query))
+		if got < test.min || got >= test.max {
+			t.Errorf("JWDist(%q, %q) = %f, want in [%.2f, %.2f)", test.name, test.query, got, test.min, test.max)
+		}
+
+		decomposed := JWDist(Normalize(norm.NFD.String(test.name)), Normalize(test.query))
+		if decomposed != got {
+			t.Errorf("JWDist of NFD %q = %f, but %f when composed", test.name, decomposed, got)
+		}
+	}
+
+	// Multi-byte letters count as one, not as their bytes
+	if got := JWDist("rżąka", "rżąka"); got != 1.0 {
+		t.Errorf("JWDist of the same name = %f, want 1", got)
+	}
+	if got := JWDist("żąka", "żąkb"); got < 0.8 {
+		t.Errorf("JWDist with one letter off = %f, want at least 0.8", got)
+	}
+}
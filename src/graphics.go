@@ -1,6 +1,11 @@
 package scheduler
 
 import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
 	"time"
 	"strings"
 	"strconv"
@@ -14,6 +19,7 @@ type SearchFocused int
 const (
 	ConnectionFocused SearchFocused = iota
 	FuzzyFocused
+	FavouritesFocused
 	TableFocused
 )
 
@@ -22,14 +28,69 @@ type UI struct {
 	
 	Times *tview.Table
 	TimesBanner *tview.Table
+	TimesStats *tview.Table
+	TimesHourly *tview.Table
 	TimesConnectionId int
+	// Counts only the low-floor departures, like the search it was opened from
+	TimesLowFloor bool
+	// Shows only the column of today's type of day
+	TimesTodayOnly bool
 	
 	SearchTable *tview.Table
 	SearchConnection *tview.Form
 	SearchFuzzy *tview.Form
 	CurrentFocus SearchFocused
+	JourneyOrder JourneyOrder
+	JourneyFilter JourneyFilter
+	JourneysDisplayed bool
+	// Fuzzy search counts only departures of low-floor vehicles
+	FuzzyLowFloor bool
 
+	// Redoes the last search, so it can be recomputed after the clock changed
+	RepeatSearch func()
+	// Called once the journeys planned in the background are on screen
+	Planned func()
 	ConnectionsDisplayed []Connection
+	// What the displayed rows were found by, to highlight the matching letters
+	Queries SearchQueries
+	// Which of the forms the displayed rows came from
+	ResultsFrom SearchFocused
+
+	// Queries typed so far, Up and Down in an empty field go through them
+	History History
+	// Where to come back to once the database is loaded, nil to start afresh
+	Restore *Session
+	// Stops whose departure left at the last tick of the clock, forgotten
+	// once the table shows anything other than what the tick recomputed
+	Departed map[int]bool
+	// Set while the tick recomputes the tables
+	Ticking bool
+
+	TimeTravel *tview.Form
+
+	JourneyDetail *tview.Table
+	// Page to go back to from the journey detail
+	JourneyReturn string
+
+	LineFilter LineFilter
+	LineFilterForm *tview.Form
+
+	Favourites []Favourite
+	FavouritesTable *tview.Table
+	// Next departures of the favourites as last found, they're found in the
+	// background and `FavouritesShown` tells which of the searches is the last
+	FavouritesNext map[Favourite]string
+	FavouritesShown int
+
+	Reach *tview.Table
+	ReachForm *tview.Form
+	ReachOrder ReachableOrder
+	ReachResults []Reachable
+}
+
+type SearchQueries struct {
+	Stop, Direction string
+	From, To string
 }
 
 func NewUI() UI {
@@ -48,24 +109,261 @@ func Center(width, height int, p tview.Primitive) tview.Primitive {
 		AddItem(tview.NewBox(), 0, 1, false)
 }
 
+// Up and Down in the empty field, or in one showing a query from the history,
+// go through the queries typed in it before. Enter remembers the query.
+func (ui *UI) HistoryInput(field *tview.InputField, name string) {
+	shown := -1
+	field.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		queries := ui.History.Queries(name)
+		text := field.GetText()
+		// Anything typed over the query from the history, or clearing it,
+		// starts the going through anew
+		if shown >= 0 && (shown >= len(queries) || queries[shown] != text) {
+			shown = -1
+		}
+		if len(text) != 0 && shown < 0 {
+			return event
+		}
+
+		switch event.Key() {
+		case tcell.KeyUp:
+			if shown + 1 >= len(queries) {
+				return event
+			}
+			shown++
+			field.SetText(queries[shown])
+			return nil
+		case tcell.KeyDown:
+			if shown < 0 {
+				return event
+			}
+			shown--
+			if shown < 0 {
+				field.SetText("")
+			} else {
+				field.SetText(queries[shown])
+			}
+			return nil
+		}
+
+		return event
+	})
+
+	field.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter || key == tcell.KeyTab {
+			ui.History.Add(name, field.GetText(), time.Now())
+		}
+	})
+}
+
+func (ui *UI) InputText(form *tview.Form, label string) string {
+	return form.GetFormItemByLabel(label).(*tview.InputField).GetText()
+}
+
+// Keeps what's in the search fields, once something found by them is opened
+func (ui *UI) RememberQueries() {
+	now := time.Now()
+	ui.History.Add("from", ui.InputText(ui.SearchConnection, "From"), now)
+	ui.History.Add("to", ui.InputText(ui.SearchConnection, "To"), now)
+	ui.History.Add("fuzzy", ui.InputText(ui.SearchFuzzy, "Fuzzy search for"), now)
+}
+
+func (ui *UI) CurrentSession() Session {
+	page, _ := ui.Pages.GetFrontPage()
+	row, _ := ui.SearchTable.GetSelection()
+	results := "connection"
+	if ui.ResultsFrom == FuzzyFocused {
+		results = "fuzzy"
+	}
+
+	stop := -1
+	if row > 0 && row <= len(ui.ConnectionsDisplayed) {
+		stop = ui.ConnectionsDisplayed[row - 1].Stop.Id
+	}
+
+	return Session {
+		Page: page,
+		From: ui.InputText(ui.SearchConnection, "From"),
+		To: ui.InputText(ui.SearchConnection, "To"),
+		Fuzzy: ui.InputText(ui.SearchFuzzy, "Fuzzy search for"),
+		Results: results,
+		Stop: stop,
+	}
+}
+
+// Searches again for what was on screen and opens the row of the stop it was
+// left on, the pages other than the schedule and the journey go back to
+// searching. Journeys are selected once they're planned.
+func (ui *UI) RestoreSession(database *Database, session Session) {
+	connection := func() {
+		ui.SearchConnection.GetFormItemByLabel("From").(*tview.InputField).SetText(session.From)
+		ui.SearchConnection.GetFormItemByLabel("To").(*tview.InputField).SetText(session.To)
+	}
+	fuzzy := func() {
+		ui.SearchFuzzy.GetFormItemByLabel("Fuzzy search for").(*tview.InputField).SetText(session.Fuzzy)
+	}
+
+	// NOTE(radomski): The results come from whichever is filled in last
+	if session.Results == "fuzzy" {
+		connection()
+		fuzzy()
+	} else {
+		fuzzy()
+		connection()
+	}
+
+	open := func() {
+		row := ui.RowOfStop(session.Stop)
+		if row == 0 {
+			return
+		}
+
+		ui.SearchTable.Select(row, 0)
+		app.SetFocus(ui.SearchTable)
+		ui.CurrentFocus = TableFocused
+		if session.Page == "times" || session.Page == "stats" || session.Page == "journey" {
+			ui.OpenSearchRow(database, row)
+		}
+		// A journey opens on its own page, only a stop has statistics
+		if name, _ := ui.Pages.GetFrontPage(); session.Page == "stats" && name == "times" {
+			ui.Pages.SwitchToPage("stats")
+		}
+	}
+
+	if session.Results != "fuzzy" && len(session.From) != 0 && len(session.To) != 0 {
+		ui.Planned = open
+	} else {
+		open()
+	}
+}
+
+// Row of the search table showing the stop, 0 when none does
+func (ui *UI) RowOfStop(id int) int {
+	for i, connection := range ui.ConnectionsDisplayed {
+		if connection.Stop.Id == id {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+func (ui *UI) OpenSearchRow(database *Database, row int) {
+	if row == 0 || row > len(ui.ConnectionsDisplayed) {
+		return
+	}
+
+	ui.RememberQueries()
+	connection := ui.ConnectionsDisplayed[row - 1]
+	if journey, ok := connection.Detail(); ok {
+		ui.ShowJourney(database, journey, "search")
+		return
+	}
+
+	// TODO(radomski): This is broken when searching, because it doesn't use the
+	// relative rows of the stops that are currently on display
+	ui.RefreshTimesInfo(connection)
+	ui.Pages.SwitchToPage("times")
+}
+
+// How many stop names are offered under the From and To fields
+const SuggestionCount = 8
+
+// Offers the best matching stop names while typing, the one picked with the
+// arrows or Tab is passed on pinned, so only the stops of that name match
+func SuggestStops(field *tview.InputField, capture func(text string)) {
+	typed := ""
+	field.SetAutocompleteFunc(func(text string) []string {
+		typed = text
+		index := CurrentSearchIndex()
+		if index == nil || len(text) == 0 {
+			return nil
+		}
+
+		return index.Suggest(text, SuggestionCount)
+	})
+
+	// NOTE(radomski): Picking a suggestion changes the text without asking
+	// for new ones, so only a picked name differs from the typed text
+	field.SetChangedFunc(func(text string) {
+		if index := CurrentSearchIndex(); text != typed && index != nil && index.Has(text) {
+			text = PinName(text)
+		}
+		capture(text)
+	})
+}
+
+// How long the typing in From and To has to stop for before planning
+const PlanDelay = 300 * time.Millisecond
+
 func (ui *UI) CreateSearchInputFlex(database *Database) (input *tview.Flex) {
 	ui.SearchConnection = tview.NewForm()
 	ui.SearchFuzzy = tview.NewForm()
 	input = tview.NewFlex()
 	
-	showConnectionResults := func(from, to string) {
+	// NOTE(radomski): Planning on the whole database takes a while, so it waits
+	// for the typing to stop and runs away from the drawing. Whatever was planned
+	// for a query typed over in the meantime is dropped.
+	planned := 0
+	var planTimer *time.Timer
+	planJourneys := func(from, to string, constraints *Constraints) {
+		plan := planned
+		ticking := ui.Ticking
+		planTimer = time.AfterFunc(PlanDelay, func() {
+			journeys := PlanJourneys(database, from, to, Now(), constraints)
+			var connections []Connection
+			if len(journeys) == 0 {
+				// The planner needs the whole database, until then only direct rides
+				connections = SortConnectionsOnTime(FindConnections(from, to, database.Stops, constraints))
+			}
+
+			app.QueueUpdateDraw(func() {
+				if plan != planned || ui.ResultsFrom != ConnectionFocused {
+					return
+				}
+
+				// NOTE(radomski): Planned for the tick, the journeys that left
+				// are still marked
+				ui.Ticking = ticking
+				ui.KeepSelection(func() {
+					if len(journeys) != 0 {
+						ui.PopulateJourneysTable(journeys)
+					} else {
+						ui.PopulateConnectionsTable(connections)
+					}
+				})
+				ui.Ticking = false
+				ui.NoteAliases(from, to)
+
+				if planned := ui.Planned; planned != nil {
+					ui.Planned = nil
+					planned()
+				}
+			})
+		})
+	}
+
+	showConnectionResults := func(from, to string, constraints *Constraints) {
+		planned++
+		if planTimer != nil {
+			planTimer.Stop()
+		}
+
+		ui.Queries = SearchQueries { From: from, To: to }
 		if len(to) == 0 && len(from) == 0 {
-			connections := ConnectionsFromStops(database.Stops)
+			connections := ConnectionsFromStops(database.Stops, constraints.LowFloor)
 			ui.PopulateSearchTable(connections)
 			ui.SearchTable.ScrollToBeginning()
+		} else if len(to) != 0 && len(from) != 0 {
+			planJourneys(from, to, constraints)
 		} else {
+			defer ui.NoteAliases(from, to)
+
 			var connections []Connection
-			if len(to) != 0 && len(from) != 0 {
-				connections = FindConnections(from, to, database.Stops)			
-			} else if len(to) == 0 && len(from) != 0 {
-				connections = FindConnectionsOnlyFrom(from, database.Stops)
-			} else if len(to) != 0 && len(from) == 0 {
-				connections = FindConnectionsOnlyTo(to, database.Stops)
+			if len(from) != 0 {
+				connections = FindConnectionsOnlyFrom(from, database.Stops, constraints)
+			} else {
+				connections = FindConnectionsOnlyTo(to, database.Stops, constraints)
 			}
 
 			sorted := SortConnectionsOnTime(connections)
@@ -75,40 +373,107 @@ func (ui *UI) CreateSearchInputFlex(database *Database) (input *tview.Flex) {
 
 	from := ""
 	to := ""
+	via := ""
+	avoid := ""
+	lowFloor := false
+	repeatConnection := func() {
+		ui.ResultsFrom = ConnectionFocused
+		constraints := NewConstraints(via, avoid)
+		constraints.Lines = ui.LineFilter
+		constraints.LowFloor = lowFloor
+		showConnectionResults(from, to, constraints)
+	}
+
 	captureFrom := func(text string) {
 		from = text
-		showConnectionResults(from, to)
+		ui.RepeatSearch = repeatConnection
+		repeatConnection()
 	}
 
 	captureTo := func(text string) {
 		to = text
-		showConnectionResults(from, to)
+		ui.RepeatSearch = repeatConnection
+		repeatConnection()
+	}
+
+	captureVia := func(text string) {
+		via = text
+		ui.RepeatSearch = repeatConnection
+		repeatConnection()
+	}
+
+	captureAvoid := func(text string) {
+		avoid = text
+		ui.RepeatSearch = repeatConnection
+		repeatConnection()
+	}
+
+	captureLowFloor := func(checked bool) {
+		lowFloor = checked
+		ui.RepeatSearch = repeatConnection
+		repeatConnection()
 	}
 
 	fuzzyTerm := ""
 	showFuzzyResults := func() {
-		nstops := FindInStops(database.Stops, fuzzyTerm)
-		connections := ConnectionsFromStops(nstops)
+		query, err := ParseQuery(fuzzyTerm)
+		if err != nil {
+			// NOTE(radomski): The results of the last query that made sense
+			// stay, so they don't flicker away while typing a quote or a key
+			ui.SearchFuzzy.SetFieldTextColor(tcell.ColorRed).
+				SetTitle("Fuzzy form: " + err.Error())
+			return
+		}
+
+		ui.SearchFuzzy.SetFieldTextColor(tview.Styles.PrimaryTextColor).
+			SetTitle("Fuzzy form")
+
+		query.LowFloor = ui.FuzzyLowFloor
+		ui.Queries = SearchQueries { Stop: query.Name(), Direction: query.Direction }
+		nstops := ui.LineFilter.Stops(FindInStops(database.Stops, query))
+		connections := ConnectionsFromStops(nstops, ui.FuzzyLowFloor)
+		for i := range connections {
+			connections[i].Alias = AliasMatched(connections[i].Stop.Name, query.Name())
+		}
 		ui.PopulateSearchTable(connections)
 	}
 	
 	captureFuzzy := func(text string) {
 		fuzzyTerm = text
+		ui.ResultsFrom = FuzzyFocused
+		ui.Planned = nil
 		if len(fuzzyTerm) != 0 {
+			ui.RepeatSearch = showFuzzyResults
 			showFuzzyResults()
 		} else {
-			connections := ConnectionsFromStops(database.Stops)
-			ui.PopulateSearchTable(connections)
+			ui.SearchFuzzy.SetFieldTextColor(tview.Styles.PrimaryTextColor).
+				SetTitle("Fuzzy form")
+			ui.RepeatSearch = ui.ShowAllStops(database)
+			ui.RepeatSearch()
 			ui.SearchTable.ScrollToBeginning()
 		}
 	}
 
 	ui.SearchConnection.
-	AddInputField("From", "", 20, nil, captureFrom).
-	AddInputField("To", "", 20, nil, captureTo)
+	AddInputField("From", "", 20, nil, nil).
+	AddInputField("To", "", 20, nil, nil).
+	AddInputField("Via", "", 20, nil, captureVia).
+	AddInputField("Avoid", "", 20, nil, captureAvoid).
+	AddCheckbox("Low-floor only", false, captureLowFloor)
+
+	SuggestStops(ui.SearchConnection.GetFormItemByLabel("From").(*tview.InputField), captureFrom)
+	SuggestStops(ui.SearchConnection.GetFormItemByLabel("To").(*tview.InputField), captureTo)
+	ui.HistoryInput(ui.SearchConnection.GetFormItemByLabel("From").(*tview.InputField), "from")
+	ui.HistoryInput(ui.SearchConnection.GetFormItemByLabel("To").(*tview.InputField), "to")
 
 	ui.SearchFuzzy.
-	AddInputField("Fuzzy search for", "", 20, nil, captureFuzzy)
+	AddInputField("Fuzzy search for", "", 20, nil, captureFuzzy).
+	AddCheckbox("Low-floor only", false, func(checked bool) {
+		ui.FuzzyLowFloor = checked
+		captureFuzzy(fuzzyTerm)
+	})
+
+	ui.HistoryInput(ui.SearchFuzzy.GetFormItemByLabel("Fuzzy search for").(*tview.InputField), "fuzzy")
 
 	ui.SearchConnection.SetBorder(true).
 		SetTitle("Connection form").
@@ -124,9 +489,57 @@ func (ui *UI) CreateSearchInputFlex(database *Database) (input *tview.Flex) {
 	return
 }
 
+// Adds to the title which of the stops were found by their aliases
+func (ui *UI) NoteAliases(queries ...string) {
+	index := CurrentSearchIndex()
+	if index == nil {
+		return
+	}
+
+	var notes []string
+	for _, query := range queries {
+		if _, pinned := PinnedName(query); pinned || len(query) == 0 {
+			continue
+		}
+
+		for _, match := range index.AliasMatches(query) {
+			notes = append(notes, match.Alias + " = " + match.Name)
+		}
+	}
+
+	if len(notes) != 0 {
+		ui.SearchTable.SetTitle(ui.SearchTable.GetTitle() + ", aliases: " + strings.Join(notes, ", "))
+	}
+}
+
+func (ui *UI) ShowAllStops(database *Database) func() {
+	return func() {
+		ui.Queries = SearchQueries{}
+		connections := ConnectionsFromStops(ui.LineFilter.Stops(database.Stops), ui.FuzzyLowFloor)
+		ui.PopulateSearchTable(connections)
+	}
+}
+
+func (ui *UI) SearchTitle() string {
+	title := "Stops and their data"
+	if IsTimeTravelling() {
+		title += " as of " + Now().Format(TimeTravelLayout)
+	}
+	if !ui.LineFilter.IsEmpty() {
+		title += ", lines: " + ui.LineFilter.String()
+	}
+
+	return title
+}
+
 func (ui *UI) PopulateSearchTable(connections []Connection) {
 	ui.SearchTable.Clear()
+	if !ui.Ticking {
+		ui.Departed = nil
+	}
 	ui.ConnectionsDisplayed = connections
+	ui.JourneysDisplayed = false
+	ui.SearchTable.SetTitle(ui.SearchTitle())
 
 	headers := "Line number;Direction;Stop name;Departure in"
 	for c, header := range strings.Split(headers, ";") {
@@ -139,44 +552,130 @@ func (ui *UI) PopulateSearchTable(connections []Connection) {
 			SetAlign(tview.AlignCenter).SetExpansion(1)
 		ui.SearchTable.SetCell(r + 1, 0, cell)
 
-		cell = tview.NewTableCell(connection.Stop.Direction).
+		cell = tview.NewTableCell(HighlightMatch(connection.Stop.Direction, ui.Queries.Direction)).
 			SetAlign(tview.AlignCenter).SetExpansion(1)
 		ui.SearchTable.SetCell(r + 1, 1, cell)
 
-		cell = tview.NewTableCell(connection.Stop.Name).
+		name := HighlightMatch(connection.Stop.Name, ui.Queries.Stop)
+		if len(connection.Alias) != 0 {
+			name += " (alias " + connection.Alias + ")"
+		}
+		cell = tview.NewTableCell(name).
 			SetAlign(tview.AlignCenter).SetExpansion(1)
 		ui.SearchTable.SetCell(r + 1, 2, cell)
 
 		cell = tview.NewTableCell(connection.InfoNext).SetAlign(tview.AlignCenter)
-		ui.SearchTable.SetCell(r + 1, 3, cell)
+		ui.SearchTable.SetCell(r + 1, 3, ui.MarkDeparture(cell, connection))
 	}
 }
 
 func (ui *UI) PopulateConnectionsTable(connections []Connection) {
 	ui.SearchTable.Clear()
+	if !ui.Ticking {
+		ui.Departed = nil
+	}
 	ui.ConnectionsDisplayed = connections
+	ui.JourneysDisplayed = false
+	ui.SearchTable.SetTitle(ui.SearchTitle())
 
 	headers := "Line number;Direction;Departure in"
+	constrained := false
+	for _, connection := range connections {
+		constrained = constrained || len(connection.Constraints) != 0
+	}
+	if constrained {
+		headers += ";Constraints"
+	}
+
 	for c, header := range strings.Split(headers, ";") {
 		cell := tview.NewTableCell(header).SetAlign(tview.AlignCenter).SetExpansion(1)
 		ui.SearchTable.SetCell(0, c, cell)
 	}
 
 	for r, connection := range connections {
-		cell := tview.NewTableCell(strconv.Itoa(connection.Stop.LineNr)).
+		cell := tview.NewTableCell(connection.Lines()).
 			SetAlign(tview.AlignCenter).SetExpansion(1)
 		ui.SearchTable.SetCell(r + 1, 0, cell)
 
-		cell = tview.NewTableCell(connection.Path).
+		path := connection.Path
+		if ride := connection.Ride; len(ride) != 0 {
+			path = HighlightMatch(ride[0].Name, ui.Queries.From) + " -> " +
+				HighlightMatch(ride[len(ride) - 1].Name, ui.Queries.To)
+		}
+		cell = tview.NewTableCell(path).
 			SetAlign(tview.AlignCenter).SetExpansion(1)
 		ui.SearchTable.SetCell(r + 1, 1, cell)
 
 		cell = tview.NewTableCell(connection.InfoNext).
 			SetAlign(tview.AlignCenter).SetExpansion(1)
-		ui.SearchTable.SetCell(r + 1, 2, cell)
+		ui.SearchTable.SetCell(r + 1, 2, ui.MarkDeparture(cell, connection))
+
+		if constrained {
+			cell = tview.NewTableCell(connection.Constraints).
+				SetAlign(tview.AlignCenter).SetExpansion(1)
+			ui.SearchTable.SetCell(r + 1, 3, cell)
+		}
 	}	
 }
 
+func (ui *UI) PopulateJourneysTable(journeys []Journey) {
+	ui.SearchTable.Clear()
+	if !ui.Ticking {
+		ui.Departed = nil
+	}
+	ui.JourneysDisplayed = true
+	title := fmt.Sprintf("Journeys by %s, %s", ui.JourneyOrder, ui.JourneyFilter)
+	if !ui.LineFilter.IsEmpty() {
+		title += ", lines: " + ui.LineFilter.String()
+	}
+	ui.SearchTable.SetTitle(title + " (Ctrl+O order, Ctrl+F filter)")
+
+	journeys = SortJourneys(FilterJourneys(journeys, ui.JourneyFilter), ui.JourneyOrder)
+	ui.ConnectionsDisplayed = nil
+	for _, journey := range journeys {
+		ui.ConnectionsDisplayed = append(ui.ConnectionsDisplayed, ConnectionFromJourney(journey))
+	}
+
+	headers := "Line number;Direction;Departure in;Arrives;Transfers;Walking"
+	constrained := false
+	for _, journey := range journeys {
+		constrained = constrained || len(journey.Constraints) != 0
+	}
+	if constrained {
+		headers += ";Constraints"
+	}
+
+	for c, header := range strings.Split(headers, ";") {
+		cell := tview.NewTableCell(header).SetAlign(tview.AlignCenter).SetExpansion(1)
+		ui.SearchTable.SetCell(0, c, cell)
+	}
+
+	now := Now()
+	for r, journey := range journeys {
+		minNext := MinutesBetween(now, journey.First().Departure.Time(now))
+		arrival := journey.Last().Arrival
+		columns := []string {
+			journey.Lines(),
+			journey.Path(),
+			fmt.Sprintf("In %d min", minNext),
+			fmt.Sprintf("%02d:%02d (%d min)", arrival.Hour, arrival.Minute, journey.Duration(now)),
+			strconv.Itoa(journey.Transfers()),
+			fmt.Sprintf("%d min", journey.WalkingMinutes()),
+		}
+		if constrained {
+			columns = append(columns, journey.Constraints)
+		}
+
+		for c, column := range columns {
+			cell := tview.NewTableCell(column).SetAlign(tview.AlignCenter).SetExpansion(1)
+			if c == 2 {
+				cell = ui.MarkDeparture(cell, ui.ConnectionsDisplayed[r])
+			}
+			ui.SearchTable.SetCell(r + 1, c, cell)
+		}
+	}
+}
+
 func (ui *UI) CreateSearchPage(database *Database) (title string, content tview.Primitive) {
 	ui.SearchTable = tview.NewTable()
 
@@ -185,35 +684,163 @@ func (ui *UI) CreateSearchPage(database *Database) (title string, content tview.
 		SetSeparator(tview.Borders.Vertical)
 
 	ui.SearchTable.SetBorder(true).
-		SetTitle("Stops and their data").
+		SetTitle(ui.SearchTitle()).
 		SetTitleAlign(tview.AlignCenter)
 	ui.SearchTable.SetSelectedFunc(func(row, _ int) {
-		if row != 0 {
-			// TODO(radomski): This is broken when searching, because it doesn't use the
-			// relative rows of the stops that are currently on display
-			ui.RefreshTimesInfo(ui.ConnectionsDisplayed[row - 1])
-			ui.Pages.SwitchToPage("times")
-		}
+		ui.OpenSearchRow(database, row)
 	})
 
-	connections := ConnectionsFromStops(database.Stops)
-	ui.PopulateSearchTable(connections)
+	ui.RepeatSearch = ui.ShowAllStops(database)
+	ui.RepeatSearch()
 
 	input := ui.CreateSearchInputFlex(database)
+	input.AddItem(ui.CreateFavouritesTable(database), 0, 2, false)
 
 	return "search", tview.NewFlex().
 		AddItem(tview.NewFlex().
 			SetDirection(tview.FlexRow).
 			AddItem(ui.SearchTable, 0, 1, false).
-			AddItem(input, 9, 0, true),
+			AddItem(input, 15, 0, true),
 		0, 1, true)
 }
 
+// Saved stops, connections and lines with their next departures, Enter shows
+// the favourite and Delete forgets it
+func (ui *UI) CreateFavouritesTable(database *Database) *tview.Table {
+	ui.FavouritesTable = tview.NewTable()
+	ui.FavouritesTable.SetSelectable(true, false).
+		SetSeparator(tview.Borders.Vertical)
+	ui.FavouritesTable.SetBorder(true).
+		SetTitle("Favourites").
+		SetTitleAlign(tview.AlignLeft)
+
+	favourites, err := ReadFavourites(CreateConfigPath("favourites"))
+	ui.Favourites = favourites
+	if errs, ok := err.(LineErrors); ok {
+		ui.FavouritesTable.SetTitle("Favourites: " + errs.Short())
+	} else if err != nil {
+		ui.FavouritesTable.SetTitle("Favourites: " + err.Error())
+	}
+
+	ui.FavouritesTable.SetSelectedFunc(func(row, _ int) {
+		if row < len(ui.Favourites) {
+			ui.OpenFavourite(database, ui.Favourites[row])
+		}
+	})
+	ui.FavouritesTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		row, _ := ui.FavouritesTable.GetSelection()
+		if event.Key() != tcell.KeyDelete || row >= len(ui.Favourites) {
+			return event
+		}
+
+		ui.Favourites = append(ui.Favourites[:row:row], ui.Favourites[row + 1:]...)
+		ui.WriteFavourites(database)
+		return nil
+	})
+
+	ui.PopulateFavouritesTable(database)
+	return ui.FavouritesTable
+}
+
+func (ui *UI) PopulateFavouritesTable(database *Database) {
+	ui.FavouritesTable.Clear()
+	if len(ui.Favourites) == 0 {
+		ui.FavouritesTable.SetCell(0, 0, tview.NewTableCell("Ctrl+B saves the row, Ctrl+S its stop").
+			SetTextColor(tcell.ColorGray).SetSelectable(false))
+		return
+	}
+
+	// The departures found the last time stay until the new ones are there
+	favourites := append([]Favourite(nil), ui.Favourites...)
+	for r, favourite := range favourites {
+		next, present := ui.FavouritesNext[favourite]
+		if !present {
+			next = "..."
+		}
+
+		ui.FavouritesTable.SetCell(r, 0, tview.NewTableCell(favourite.String()).SetMaxWidth(30))
+		ui.FavouritesTable.SetCell(r, 1, tview.NewTableCell(next).SetExpansion(1))
+	}
+
+	if (database.Status & DatabaseComplete) == 0 {
+		return
+	}
+
+	// NOTE(radomski): Every saved connection is planned, which takes a while,
+	// so it's done away from the drawing
+	ui.FavouritesShown++
+	shown := ui.FavouritesShown
+	go func() {
+		next := make([]string, len(favourites))
+		for i, favourite := range favourites {
+			next[i] = favourite.NextDeparture(database)
+		}
+
+		app.QueueUpdateDraw(func() {
+			if shown != ui.FavouritesShown {
+				return
+			}
+
+			ui.FavouritesNext = make(map[Favourite]string)
+			for r, favourite := range favourites {
+				ui.FavouritesNext[favourite] = next[r]
+				ui.FavouritesTable.GetCell(r, 1).SetText(next[r])
+			}
+		})
+	}()
+}
+
+func (ui *UI) WriteFavourites(database *Database) {
+	if err := WriteFavourites(CreateConfigPath("favourites"), ui.Favourites); err != nil {
+		ui.FavouritesTable.SetTitle("Favourites: " + err.Error())
+	} else {
+		ui.FavouritesTable.SetTitle("Favourites")
+	}
+
+	ui.PopulateFavouritesTable(database)
+}
+
+func (ui *UI) SaveFavourite(database *Database, favourite Favourite) {
+	favourites, added := AddFavourite(ui.Favourites, favourite)
+	if !added {
+		ui.FavouritesTable.SetTitle("Favourites: already saved " + favourite.String())
+		return
+	}
+
+	ui.Favourites = favourites
+	ui.WriteFavourites(database)
+}
+
+// Searches for the favourite the same way it would be typed, with the names
+// pinned so only the saved stops come up
+func (ui *UI) OpenFavourite(database *Database, favourite Favourite) {
+	switch favourite.Kind {
+	case FavouriteConnection:
+		ui.SearchConnection.GetFormItemByLabel("From").(*tview.InputField).SetText(PinName(favourite.Stop))
+		ui.SearchConnection.GetFormItemByLabel("To").(*tview.InputField).SetText(PinName(favourite.To))
+	case FavouriteLine:
+		for _, stop := range database.Stops {
+			if stop.LineNr == favourite.LineNr && stop.Direction == favourite.Direction &&
+				stop.Name == favourite.Stop {
+				ui.RefreshTimesInfo(ConnectionFromStop(stop, false))
+				ui.Pages.SwitchToPage("times")
+				return
+			}
+		}
+	default:
+		query := "stop:\"" + PinName(favourite.Stop) + "\""
+		ui.SearchFuzzy.GetFormItemByLabel("Fuzzy search for").(*tview.InputField).SetText(query)
+	}
+
+	app.SetFocus(ui.SearchTable)
+	ui.CurrentFocus = TableFocused
+}
+
 func (ui *UI) CreateTimesPage() (title string, content tview.Primitive) {
 	ui.Times = tview.NewTable()
 
-	ui.Times.SetSelectable(true, true).SetSeparator(tview.Borders.Vertical)	
-	ui.Times.SetBorder(true).SetTitle("Departures/Arrivals").SetTitleAlign(tview.AlignCenter)
+	ui.Times.SetFixed(1, 0).SetSelectable(true, true).SetSeparator(tview.Borders.Vertical)	
+	ui.Times.SetBorder(true).SetTitleAlign(tview.AlignCenter)
 	ui.Times.SetDoneFunc(func (key tcell.Key) {
 		ui.Pages.SwitchToPage("search")
 	})
@@ -231,14 +858,425 @@ func (ui *UI) CreateTimesPage() (title string, content tview.Primitive) {
 	return "times", Center(80, 30, flex)
 }
 
+// Statistics of the stop opened on the times page, Ctrl+A and Esc go back to
+// its schedule. It takes the whole screen and the hours scroll, so it fits any
+// terminal.
+func (ui *UI) CreateStatsPage() (title string, content tview.Primitive) {
+	ui.TimesStats = tview.NewTable()
+
+	ui.TimesStats.SetSeparator(tview.Borders.Vertical)
+	ui.TimesStats.SetBorder(true).SetTitle("Statistics").SetTitleAlign(tview.AlignLeft)
+
+	ui.TimesHourly = tview.NewTable()
+
+	ui.TimesHourly.SetFixed(1, 0).SetSelectable(true, false).SetSeparator(tview.Borders.Vertical)
+	ui.TimesHourly.SetBorder(true).SetTitle("Departures per hour").SetTitleAlign(tview.AlignLeft)
+	ui.TimesHourly.SetDoneFunc(func(key tcell.Key) {
+		ui.Pages.SwitchToPage("times")
+	})
+
+	return "stats", tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(ui.TimesStats, 6, 0, false).
+		AddItem(ui.TimesHourly, 0, 1, true)
+}
+
+func (ui *UI) CreateJourneyPage() (title string, content tview.Primitive) {
+	ui.JourneyDetail = tview.NewTable()
+
+	ui.JourneyDetail.SetFixed(1, 0).
+		SetSelectable(true, false).
+		SetSeparator(tview.Borders.Vertical)
+	ui.JourneyDetail.SetBorder(true).
+		SetTitle("Journey").
+		SetTitleAlign(tview.AlignCenter)
+	ui.JourneyDetail.SetDoneFunc(func(key tcell.Key) {
+		ui.Pages.SwitchToPage(ui.JourneyReturn)
+	})
+
+	return "journey", Center(100, 40, ui.JourneyDetail)
+}
+
+// Stop by stop timeline of the journey, Esc goes back to `from`
+func (ui *UI) ShowJourney(database *Database, journey Journey, from string) {
+	ui.JourneyReturn = from
+	ui.JourneyDetail.Clear()
+	ui.JourneyDetail.SetTitle(journey.Summary())
+
+	for c, header := range strings.Split(TimelineHeaders, ";") {
+		cell := tview.NewTableCell(header).SetAlign(tview.AlignCenter).SetExpansion(1)
+		ui.JourneyDetail.SetCell(0, c, cell)
+	}
+
+	for r, entry := range journey.Timeline(database.Stops) {
+		for c, column := range entry.Columns() {
+			cell := tview.NewTableCell(column).SetExpansion(1)
+			if len(entry.Note) == 0 {
+				cell.SetTextColor(tcell.ColorGray)
+			}
+			ui.JourneyDetail.SetCell(r + 1, c, cell)
+		}
+	}
+
+	ui.JourneyDetail.ScrollToBeginning()
+	ui.Pages.SwitchToPage("journey")
+}
+
+func (ui *UI) CreateTimeTravelPage(database *Database) (title string, content tview.Primitive) {
+	ui.TimeTravel = tview.NewForm()
+
+	back := func() {
+		ui.Pages.HidePage("timetravel")
+		ui.RefreshAfterClockChange(database)
+	}
+
+	ui.TimeTravel.
+	AddInputField("Show schedule as of", "", 20, nil, nil).
+	AddButton("Travel", func() {
+		item := ui.TimeTravel.GetFormItem(0).(*tview.InputField)
+		at, err := ParseScheduleTime(item.GetText())
+		if err != nil {
+			ui.TimeTravel.SetTitle(err.Error())
+			return
+		}
+
+		SetClock(NewOffsetClock(at))
+		back()
+	}).
+	AddButton("Back to now", func() {
+		SetClock(SystemClock{})
+		back()
+	}).
+	AddButton("Cancel", func() {
+		ui.Pages.HidePage("timetravel")
+	})
+
+	ui.TimeTravel.SetCancelFunc(func() {
+		ui.Pages.HidePage("timetravel")
+	})
+	ui.TimeTravel.SetBorder(true).
+		SetTitle("Time travel").
+		SetTitleAlign(tview.AlignLeft)
+
+	return "timetravel", Center(60, 7, ui.TimeTravel)
+}
+
+func (ui *UI) ShowTimeTravel() {
+	item := ui.TimeTravel.GetFormItem(0).(*tview.InputField)
+	item.SetText(Now().Format(TimeTravelLayout))
+	ui.TimeTravel.SetFocus(0)
+	ui.TimeTravel.SetTitle("Time travel")
+	ui.Pages.ShowPage("timetravel")
+}
+
+func (ui *UI) CreateLineFilterPage() (title string, content tview.Primitive) {
+	ui.LineFilterForm = tview.NewForm()
+
+	for mode := Tram; mode < ModeCount; mode++ {
+		ui.LineFilterForm.AddCheckbox(strings.Title(mode.String()), true, nil)
+	}
+
+	ui.LineFilterForm.
+	AddInputField("Only lines", "", 20, nil, nil).
+	AddInputField("Except lines", "", 20, nil, nil).
+	AddButton("Apply", func() {
+		text := func(i int) string {
+			return ui.LineFilterForm.GetFormItem(int(ModeCount) + i).(*tview.InputField).GetText()
+		}
+
+		filter, err := ParseLineFilter(text(0), text(1))
+		if err != nil {
+			ui.LineFilterForm.SetTitle(err.Error())
+			return
+		}
+
+		for mode := Tram; mode < ModeCount; mode++ {
+			checkbox := ui.LineFilterForm.GetFormItem(int(mode)).(*tview.Checkbox)
+			filter.DenyModes[mode] = filter.DenyModes[mode] || !checkbox.IsChecked()
+		}
+
+		ui.LineFilter = filter
+		ui.Pages.HidePage("lines")
+		ui.RepeatSearch()
+	}).
+	AddButton("Reset", func() {
+		ui.LineFilter = LineFilter{}
+		ui.Pages.HidePage("lines")
+		ui.RepeatSearch()
+	}).
+	AddButton("Cancel", func() {
+		ui.Pages.HidePage("lines")
+	})
+
+	ui.LineFilterForm.SetCancelFunc(func() {
+		ui.Pages.HidePage("lines")
+	})
+	ui.LineFilterForm.SetBorder(true).
+		SetTitle("Lines and modes").
+		SetTitleAlign(tview.AlignLeft)
+
+	return "lines", Center(50, 17, ui.LineFilterForm)
+}
+
+// Fills the form with the filter in use, so cancelling leaves it as it was
+func (ui *UI) ShowLineFilter() {
+	for mode := Tram; mode < ModeCount; mode++ {
+		checkbox := ui.LineFilterForm.GetFormItem(int(mode)).(*tview.Checkbox)
+		checkbox.SetChecked(!ui.LineFilter.DenyModes[mode])
+	}
+
+	only := LineFilter { Modes: ui.LineFilter.Modes, Lines: ui.LineFilter.Lines }
+	except := LineFilter { DenyLines: ui.LineFilter.DenyLines }
+	ui.LineFilterForm.GetFormItem(int(ModeCount)).(*tview.InputField).SetText(only.String())
+	ui.LineFilterForm.GetFormItem(int(ModeCount) + 1).(*tview.InputField).
+		SetText(strings.Replace(except.String(), "no ", "", -1))
+
+	ui.LineFilterForm.SetFocus(0)
+	ui.LineFilterForm.SetTitle("Lines and modes")
+	ui.Pages.ShowPage("lines")
+}
+
+// Departures leaving within this many minutes are marked
+const ImminentMinutes = 2
+
+// Departures about to leave stand out in red, the stops whose departure just
+// left are grayed out until the next tick
+func (ui *UI) MarkDeparture(cell *tview.TableCell, connection Connection) *tview.TableCell {
+	switch {
+	case ui.Departed[connection.Stop.Id] && connection.Minutes > 0:
+		cell.SetText("Just left, next " + strings.Replace(cell.Text, "In ", "in ", 1)).
+			SetTextColor(tcell.ColorGray)
+	case connection.Minutes >= 0 && connection.Minutes <= ImminentMinutes:
+		cell.SetTextColor(tcell.ColorRed)
+	}
+
+	return cell
+}
+
+// Redraws everything counted in minutes at the start of every minute
+func (ui *UI) TickEveryMinute(database *Database) {
+	for {
+		now := Now()
+		time.Sleep(now.Truncate(time.Minute).Add(time.Minute).Sub(now))
+		if (database.Status & DatabaseComplete) == 0 {
+			continue
+		}
+
+		app.QueueUpdateDraw(func() {
+			ui.Tick(database)
+		})
+	}
+}
+
+// Recomputes the departures, keeping the selected rows and how far the tables
+// were scrolled
+func (ui *UI) Tick(database *Database) {
+	departed := make(map[int]bool)
+	for _, connection := range ui.ConnectionsDisplayed {
+		if connection.Minutes == 0 {
+			departed[connection.Stop.Id] = true
+		}
+	}
+
+	// The rows of the schedule are its hours, they stay where they were
+	row, column := ui.Times.GetSelection()
+	rowOffset, columnOffset := ui.Times.GetOffset()
+
+	ui.Departed = departed
+	ui.Ticking = true
+	ui.KeepSelection(func() {
+		ui.RefreshAfterClockChange(database)
+	})
+	ui.Ticking = false
+
+	if rows := ui.Times.GetRowCount(); rows != 0 {
+		ui.Times.Select(Min(row, rows - 1), column)
+		ui.Times.SetOffset(rowOffset, columnOffset)
+	}
+}
+
+// Keeps the same connection selected in the search table while it's
+// repopulated, at the same place on screen. When it's gone, the selection
+// stays on the row it was on.
+func (ui *UI) KeepSelection(repopulate func()) {
+	row, column := ui.SearchTable.GetSelection()
+	rowOffset, columnOffset := ui.SearchTable.GetOffset()
+	selected := row > 0 && row <= len(ui.ConnectionsDisplayed)
+	var key ConnectionKey
+	if selected {
+		key = ui.ConnectionsDisplayed[row - 1].Key()
+	}
+
+	repopulate()
+
+	if selected {
+		for i, connection := range ui.ConnectionsDisplayed {
+			if connection.Key() == key {
+				rowOffset = Max(rowOffset + i + 1 - row, 0)
+				row = i + 1
+				break
+			}
+		}
+	}
+
+	if rows := ui.SearchTable.GetRowCount(); rows != 0 {
+		ui.SearchTable.Select(Min(row, rows - 1), column)
+		ui.SearchTable.SetOffset(rowOffset, columnOffset)
+	}
+}
+
+// Everything on screen that was computed against the clock
+func (ui *UI) RefreshAfterClockChange(database *Database) {
+	ui.SearchTable.SetTitle(ui.SearchTitle())
+	ui.RepeatSearch()
+	ui.PopulateFavouritesTable(database)
+
+	if name, _ := ui.Pages.GetFrontPage(); name == "times" || name == "stats" {
+		ui.RefreshTimesInfo(ui.TimesConnection(database, ui.TimesConnectionId))
+	}
+}
+
+func (ui *UI) CreateReachPage(database *Database) (title string, content tview.Primitive) {
+	ui.Reach = tview.NewTable()
+	ui.ReachForm = tview.NewForm()
+
+	ui.Reach.SetFixed(1, 1).
+		SetSelectable(true, false).
+		SetSeparator(tview.Borders.Vertical)
+	ui.Reach.SetBorder(true).
+		SetTitle("Reachable stops").
+		SetTitleAlign(tview.AlignCenter)
+	ui.Reach.SetDoneFunc(func(key tcell.Key) {
+		app.SetFocus(ui.ReachForm)
+	})
+	ui.Reach.SetSelectedFunc(func(row, _ int) {
+		if row != 0 {
+			ui.ShowJourney(database, ui.ReachResults[row - 1].Journey, "reach")
+		}
+	})
+
+	text := func(i int) string {
+		return ui.ReachForm.GetFormItem(i).(*tview.InputField).GetText()
+	}
+
+	search := func() {
+		at := Now()
+		if len(text(1)) != 0 {
+			parsed, err := ParseScheduleTime(text(1))
+			if err != nil {
+				ui.ReachForm.SetTitle(err.Error())
+				return
+			}
+			at = parsed
+		}
+
+		minutes, err := strconv.Atoi(text(2))
+		if err != nil || minutes <= 0 {
+			ui.ReachForm.SetTitle("Minutes have to be a positive number")
+			return
+		}
+
+		ui.ReachForm.SetTitle("Reach within")
+		ui.ReachResults = ReachableStops(database, text(0), at, minutes, ui.LineFilter)
+		ui.PopulateReachTable()
+		app.SetFocus(ui.Reach)
+	}
+
+	export := func(name string, write func(io.Writer, []Reachable) error) {
+		path := CreateDataPath(name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			var f *os.File
+			if f, err = os.Create(path); err == nil {
+				err = write(f, ui.ReachResults)
+				f.Close()
+			}
+		}
+
+		if err != nil {
+			ui.ReachForm.SetTitle(err.Error())
+		} else {
+			ui.ReachForm.SetTitle("Saved to " + path)
+		}
+	}
+
+	ui.ReachForm.
+	AddInputField("From", "", 20, nil, nil).
+	AddInputField("Leaving at", "", 16, nil, nil).
+	AddInputField("Minutes", "30", 4, nil, nil).
+	AddButton("Search", search).
+	AddButton("CSV", func() {
+		export("reachable.csv", WriteReachableCSV)
+	}).
+	AddButton("JSON", func() {
+		export("reachable.json", WriteReachableJSON)
+	})
+
+	ui.ReachForm.SetHorizontal(true)
+	ui.ReachForm.SetCancelFunc(func() {
+		ui.Pages.SwitchToPage("search")
+	})
+	ui.ReachForm.SetBorder(true).
+		SetTitle("Reach within").
+		SetTitleAlign(tview.AlignLeft)
+
+	return "reach", tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(ui.ReachForm, 5, 0, true).
+		AddItem(ui.Reach, 0, 1, false)
+}
+
+func (ui *UI) ShowReach() {
+	if len(ui.ReachForm.GetFormItem(1).(*tview.InputField).GetText()) == 0 {
+		ui.ReachForm.GetFormItem(1).(*tview.InputField).SetText(Now().Format(TimeTravelLayout))
+	}
+
+	ui.Pages.SwitchToPage("reach")
+	app.SetFocus(ui.ReachForm)
+}
+
+func (ui *UI) PopulateReachTable() {
+	ui.Reach.Clear()
+	ui.Reach.SetTitle(fmt.Sprintf("%d reachable stops by %s (Ctrl+O order)",
+		len(ui.ReachResults), ui.ReachOrder))
+
+	for c, header := range strings.Split(ReachableHeaders, ";") {
+		cell := tview.NewTableCell(header).SetAlign(tview.AlignCenter).SetExpansion(1)
+		ui.Reach.SetCell(0, c, cell)
+	}
+
+	ui.ReachResults = SortReachable(ui.ReachResults, ui.ReachOrder)
+	for r, reachable := range ui.ReachResults {
+		for c, column := range reachable.Columns() {
+			cell := tview.NewTableCell(column).SetAlign(tview.AlignCenter).SetExpansion(1)
+			ui.Reach.SetCell(r + 1, c, cell)
+		}
+	}
+}
+
 func (ui *UI) CreatePages(database *Database) {
 	ui.Pages = tview.NewPages()
 	
 	name, primi := ui.CreateTimesPage()
 	ui.Pages.AddPage(name, primi, true, false)
+
+	name, primi = ui.CreateStatsPage()
+	ui.Pages.AddPage(name, primi, true, false)
 	
 	name, primi = ui.CreateSearchPage(database)
 	ui.Pages.AddPage(name, primi, true, true)
+
+	name, primi = ui.CreateReachPage(database)
+	ui.Pages.AddPage(name, primi, true, false)
+
+	name, primi = ui.CreateJourneyPage()
+	ui.Pages.AddPage(name, primi, true, false)
+
+	name, primi = ui.CreateTimeTravelPage(database)
+	ui.Pages.AddPage(name, primi, true, false)
+
+	name, primi = ui.CreateLineFilterPage()
+	ui.Pages.AddPage(name, primi, true, false)
 	ui.SetKeybindings(database)
 }
 
@@ -250,6 +1288,38 @@ func (ui *UI) SetKeybindings(database *Database) {
 			go ui.UpdateUncompleteTable(database)
 			database.RefreshWithWeb()
 			return event
+		case tcell.KeyCtrlT:
+			if name, _ := ui.Pages.GetFrontPage(); name == "timetravel" {
+				return event
+			}
+
+			ui.ShowTimeTravel()
+			return nil
+		case tcell.KeyCtrlL:
+			if name, _ := ui.Pages.GetFrontPage(); name != "search" && name != "reach" {
+				return event
+			}
+
+			ui.ShowLineFilter()
+			return nil
+		case tcell.KeyCtrlA:
+			switch name, _ := ui.Pages.GetFrontPage(); name {
+			case "times":
+				ui.Pages.SwitchToPage("stats")
+			case "stats":
+				ui.Pages.SwitchToPage("times")
+			default:
+				return event
+			}
+			return nil
+		case tcell.KeyCtrlD:
+			if name, _ := ui.Pages.GetFrontPage(); name != "times" {
+				return event
+			}
+
+			ui.TimesTodayOnly = !ui.TimesTodayOnly
+			ui.RefreshTimesInfo(ui.TimesConnection(database, ui.TimesConnectionId))
+			return nil
 		case tcell.KeyCtrlN:
 			if name, _ := ui.Pages.GetFrontPage(); name != "times" {
 				return event
@@ -258,12 +1328,11 @@ func (ui *UI) SetKeybindings(database *Database) {
 			nextId := ui.TimesConnectionId + 1
 
 			// Early out
-			if nextId > len(database.Stops) {
+			if nextId >= len(database.Stops) {
 				return event
 			}
 
-			connection := ConnectionFromStop(database.Stops[nextId])
-			ui.RefreshTimesInfo(connection)
+			ui.RefreshTimesInfo(ui.TimesConnection(database, nextId))
 		case tcell.KeyCtrlP:
 			if name, _ := ui.Pages.GetFrontPage(); name != "times" {
 				return event
@@ -276,8 +1345,43 @@ func (ui *UI) SetKeybindings(database *Database) {
 				return event
 			}
 			
-			connection := ConnectionFromStop(database.Stops[nextId])
-			ui.RefreshTimesInfo(connection)
+			ui.RefreshTimesInfo(ui.TimesConnection(database, nextId))
+		case tcell.KeyCtrlE:
+			if name, _ := ui.Pages.GetFrontPage(); name != "search" {
+				return event
+			}
+
+			ui.ShowReach()
+			return nil
+		case tcell.KeyCtrlO, tcell.KeyCtrlF:
+			if name, _ := ui.Pages.GetFrontPage(); name == "reach" && event.Key() == tcell.KeyCtrlO {
+				ui.ReachOrder = (ui.ReachOrder + 1) % ReachableOrderCount
+				ui.PopulateReachTable()
+				return nil
+			} else if name != "search" || !ui.JourneysDisplayed {
+				return event
+			}
+
+			if event.Key() == tcell.KeyCtrlO {
+				ui.JourneyOrder = (ui.JourneyOrder + 1) % JourneyOrderCount
+			} else {
+				ui.JourneyFilter = (ui.JourneyFilter + 1) % JourneyFilterCount
+			}
+			ui.RepeatSearch()
+			return nil
+		case tcell.KeyCtrlB, tcell.KeyCtrlS:
+			if name, _ := ui.Pages.GetFrontPage(); name != "search" || ui.CurrentFocus != TableFocused {
+				return event
+			}
+
+			row, _ := ui.SearchTable.GetSelection()
+			if row == 0 || row > len(ui.ConnectionsDisplayed) {
+				return nil
+			}
+
+			connection := ui.ConnectionsDisplayed[row - 1]
+			ui.SaveFavourite(database, FavouriteFromConnection(connection, event.Key() == tcell.KeyCtrlS))
+			return nil
 		case tcell.KeyCtrlSpace:
 			if name, _ := ui.Pages.GetFrontPage(); name != "search" {
 				return event;
@@ -296,14 +1400,16 @@ func (ui *UI) SetKeybindings(database *Database) {
 					id, _ := ui.SearchFuzzy.GetFocusedItemIndex()
 					item := ui.SearchFuzzy.GetFormItem(id)
 
-					input := item.(*tview.InputField)
-					input.SetText("")
+					if input, ok := item.(*tview.InputField); ok {
+						input.SetText("")
+					}
 				case ConnectionFocused:
 					id, _ := ui.SearchConnection.GetFocusedItemIndex()
 					item := ui.SearchConnection.GetFormItem(id)
 
-					input := item.(*tview.InputField)
-					input.SetText("")
+					if input, ok := item.(*tview.InputField); ok {
+						input.SetText("")
+					}
 				default:
 					return event
 				}
@@ -320,6 +1426,9 @@ func (ui *UI) SearchFocusNext() {
 		app.SetFocus(ui.SearchFuzzy)
 		ui.CurrentFocus += 1
 	case FuzzyFocused:
+		app.SetFocus(ui.FavouritesTable)
+		ui.CurrentFocus += 1
+	case FavouritesFocused:
 		app.SetFocus(ui.SearchTable)
 		ui.CurrentFocus += 1
 	case TableFocused:		
@@ -328,54 +1437,158 @@ func (ui *UI) SearchFocusNext() {
 	}
 }
 
+// Colors the letters of the text that made it match the query. The text is
+// escaped, so brackets in a stop name aren't taken for color tags.
+func HighlightMatch(text, query string) string {
+	if len(query) == 0 {
+		return tview.Escape(text)
+	}
+
+	var b strings.Builder
+	runes, marked := []rune(text), MatchedLetters(text, query)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		for end < len(runes) && marked[end] == marked[start] {
+			end++
+		}
+
+		segment := tview.Escape(string(runes[start:end]))
+		if marked[start] {
+			segment = "[yellow]" + segment + "[-]"
+		}
+		b.WriteString(segment)
+
+		start = end
+	}
+
+	return b.String()
+}
+
+// Like `MarkLowFloor`, also graying out the minutes before the `next` one and
+// reversing the `next` one. -1 when none of them left yet.
+func MarkMinutes(mins string, next int) string {
+	split := strings.Split(mins, " ")
+	for i, min := range split {
+		switch {
+		case len(min) == 0:
+		case i < next:
+			split[i] = "[gray]" + min + "[-]"
+		case i == next:
+			split[i] = "[::r]" + MarkLowFloor(min) + "[::-]"
+		default:
+			split[i] = MarkLowFloor(min)
+		}
+	}
+
+	return strings.Join(split, " ")
+}
+
+// Colors the minutes of the hour served by low-floor vehicles
+func MarkLowFloor(mins string) string {
+	split := strings.Split(mins, " ")
+	for i, min := range split {
+		if IsLowFloor(min) {
+			split[i] = "[green]" + min + "[-]"
+		}
+	}
+
+	return strings.Join(split, " ")
+}
+
+// The stop on the times page counted the same way as the one opened there
+func (ui *UI) TimesConnection(database *Database, id int) Connection {
+	return ConnectionFromStop(database.Stops[id], ui.TimesLowFloor)
+}
+
 func (ui *UI) RefreshTimesInfo(connection Connection) {
 	ui.Times.Clear()
 	ui.TimesConnectionId = connection.Stop.Id;
+	ui.TimesLowFloor = connection.LowFloor
 	
-	minsOrEmpty := func(mins []string, i int) (result string) {
+	today := DayTypeOf(Now())
+	title := "Departures/Arrivals, [green]low-floor[-], [::r]next[::-], [gray]departed[-] (Ctrl+D only today)"
+	if ui.TimesTodayOnly {
+		title = "Departures/Arrivals, [green]low-floor[-], [::r]next[::-], [gray]departed[-] (Ctrl+D every day)"
+	}
+	ui.Times.SetTitle(title)
+
+	// NOTE(radomski): Only today's column knows which departures already left
+	nowHour, nowMin, _ := Now().Clock()
+	hours := connection.Stop.Times.Hours
+	nextHour, nextMin := ClosestsBusTimeIndexes(nowHour, nowMin, connection.Stop.Times.Mins(today),
+		hours, connection.LowFloor)
+	nextOf := func(r int) int {
+		switch {
+		case nextHour == NotWorkDays || r > nextHour && nextHour != BeyondSchedule:
+			return -1
+		case r == nextHour:
+			return nextMin
+		default:
+			return math.MaxInt32
+		}
+	}
+
+	minsOrEmpty := func(day DayType, i int) (result string) {
 		result = ""
-		if len(mins) != 0 {
-			result = mins[i]
+		if mins := connection.Stop.Times.Mins(day); len(mins) != 0 {
+			if day == today {
+				result = MarkMinutes(mins[i], nextOf(i))
+			} else {
+				result = MarkLowFloor(mins[i])
+			}
 		}
 
 		return
 	}
 
-	headers := "Hour;Work Day;Saturday;Holiday"
-	for c, header := range strings.Split(headers, ";") {
-		cell := tview.NewTableCell(header).SetAlign(tview.AlignCenter).SetExpansion(1)
-		ui.Times.SetCell(0, c, cell)
+	days := []DayType { WorkDay, Saturday, Holiday }
+	if ui.TimesTodayOnly {
+		days = []DayType { today }
 	}
 
-	for r, hour := range connection.Stop.Times.Hours {
+	cell := tview.NewTableCell("Hour").SetAlign(tview.AlignCenter).SetExpansion(1)
+	ui.Times.SetCell(0, 0, cell)
+	for c, day := range days {
+		cell := tview.NewTableCell(day.String()).SetAlign(tview.AlignCenter).SetExpansion(1)
+		if day == today {
+			cell.SetText(day.String() + " (today)").SetTextColor(tcell.ColorYellow)
+		}
+		ui.Times.SetCell(0, c + 1, cell)
+	}
+
+	for r, hour := range hours {
 		cell := tview.NewTableCell(hour).SetAlign(tview.AlignRight).SetExpansion(1)
 		ui.Times.SetCell(r + 1, 0, cell)
 
-		cell = tview.NewTableCell(minsOrEmpty(connection.Stop.Times.WorkMins, r)).
-			SetAlign(tview.AlignLeft).
-			SetExpansion(1)
-		ui.Times.SetCell(r + 1, 1, cell)
-		
-		cell = tview.NewTableCell(minsOrEmpty(connection.Stop.Times.SaturdayMins, r)).
-			SetAlign(tview.AlignLeft).
-			SetExpansion(1)
-		ui.Times.SetCell(r + 1, 2, cell)
-		
-		cell = tview.NewTableCell(minsOrEmpty(connection.Stop.Times.HolidayMins, r)).
-			SetAlign(tview.AlignLeft).
-			SetExpansion(1)
-		ui.Times.SetCell(r + 1, 3, cell)
+		for c, day := range days {
+			cell = tview.NewTableCell(minsOrEmpty(day, r)).
+				SetAlign(tview.AlignLeft).
+				SetExpansion(1)
+			ui.Times.SetCell(r + 1, c + 1, cell)
+		}
+	}
+
+	// Opens on the current hour, or the last one once the day is over
+	row := len(hours)
+	if i := CurrentHourIndex(nowHour, hours); i != -1 {
+		row = i + 1
 	}
+	for c, day := range days {
+		if day == today {
+			ui.Times.Select(row, c + 1)
+		}
+	}
+	ui.Times.SetOffset(Max(row - 1, 0), 0)
 
 
 	ui.TimesBanner.Clear()
-	headers = "Line number;Direction;Stop name;Departure in"
+	headers := "Line number;Direction;Stop name;Departure in;At terminus"
 	for c, header := range strings.Split(headers, ";") {
 		cell := tview.NewTableCell(header).SetAlign(tview.AlignCenter).SetExpansion(1)
 		ui.TimesBanner.SetCell(0, c, cell)
 	}
 
-	cell := tview.NewTableCell(strconv.Itoa(connection.Stop.LineNr)).
+	cell = tview.NewTableCell(strconv.Itoa(connection.Stop.LineNr)).
 		SetAlign(tview.AlignCenter).
 		SetExpansion(1)
 	ui.TimesBanner.SetCell(1, 0, cell)
@@ -393,7 +1606,73 @@ func (ui *UI) RefreshTimesInfo(connection Connection) {
 	cell = tview.NewTableCell(connection.InfoNext).
 		SetAlign(tview.AlignCenter).
 		SetExpansion(1)
-	ui.TimesBanner.SetCell(1, 3, cell)
+	ui.TimesBanner.SetCell(1, 3, ui.MarkDeparture(cell, connection))
+
+	terminus := "-"
+	if trip := NextTrip(*connection.Stop, connection.LowFloor); trip != nil {
+		last := trip.Calls[len(trip.Calls) - 1]
+		terminus = fmt.Sprintf("%02d:%02d (trip %d)",
+			last.Departure.Hour, last.Departure.Minute, trip.Id)
+	}
+
+	cell = tview.NewTableCell(terminus).
+		SetAlign(tview.AlignCenter).
+		SetExpansion(1)
+	ui.TimesBanner.SetCell(1, 4, cell)
+
+	ui.RefreshTimesStats(*connection.Stop)
+}
+
+func (ui *UI) RefreshTimesStats(stop Stop) {
+	ui.TimesStats.Clear()
+	ui.TimesStats.SetTitle(fmt.Sprintf("Statistics of line %d towards %s at %s",
+		stop.LineNr, stop.Direction, stop.Name))
+
+	for c, header := range strings.Split(StatsHeaders, ";") {
+		cell := tview.NewTableCell(header).SetAlign(tview.AlignCenter).SetExpansion(1)
+		ui.TimesStats.SetCell(0, c, cell)
+	}
+
+	var stats []HeadwayStats
+	for day := WorkDay; day < DayTypeCount; day++ {
+		stats = append(stats, StatsForStop(stop, day))
+
+		r := int(day) + 1
+		cell := tview.NewTableCell(day.String()).SetAlign(tview.AlignCenter).SetExpansion(1)
+		ui.TimesStats.SetCell(r, 0, cell)
+
+		for c, column := range stats[day].Columns() {
+			cell := tview.NewTableCell(column).SetAlign(tview.AlignCenter).SetExpansion(1)
+			ui.TimesStats.SetCell(r, c + 1, cell)
+		}
+	}
+
+	ui.RefreshTimesHourly(stats)
+}
+
+// A row for every hour with a departure, a column of the counts for every day
+func (ui *UI) RefreshTimesHourly(stats []HeadwayStats) {
+	ui.TimesHourly.Clear()
+	ui.TimesHourly.SetCell(0, 0, tview.NewTableCell("Hour").SetAlign(tview.AlignCenter).SetExpansion(1))
+	for day := WorkDay; day < DayTypeCount; day++ {
+		cell := tview.NewTableCell(day.String()).SetAlign(tview.AlignCenter).SetExpansion(1)
+		ui.TimesHourly.SetCell(0, int(day) + 1, cell)
+	}
+
+	for r, hour := range ServedHours(stats) {
+		cell := tview.NewTableCell(strconv.Itoa(hour)).SetAlign(tview.AlignCenter).SetExpansion(1)
+		ui.TimesHourly.SetCell(r + 1, 0, cell)
+
+		for day := WorkDay; day < DayTypeCount; day++ {
+			count := "-"
+			if departures := stats[day].PerHour[hour]; departures != 0 {
+				count = strconv.Itoa(departures)
+			}
+
+			cell := tview.NewTableCell(count).SetAlign(tview.AlignCenter).SetExpansion(1)
+			ui.TimesHourly.SetCell(r + 1, int(day) + 1, cell)
+		}
+	}
 }
 
 func (ui *UI) UpdateUncompleteTable(database *Database) {
@@ -412,14 +1691,27 @@ func (ui *UI) UpdateUncompleteTable(database *Database) {
 		}
 		
 		app.QueueUpdateDraw(func() {
-			ui.SearchTable.SetTitle(info).SetTitleAlign(tview.AlignLeft)
-			connections := ConnectionsFromStops(database.Stops)
+			connections := ConnectionsFromStops(database.Stops, false)
 			ui.PopulateSearchTable(connections)
+			ui.SearchTable.SetTitle(info).SetTitleAlign(tview.AlignLeft)
 		})
 		time.Sleep(updateInterval)
 	}
 
+	// The stops shown while loading are missing what's computed at the very end
+	app.QueueUpdateDraw(func() {
+		ui.RepeatSearch()
+		ui.PopulateFavouritesTable(database)
+		if ui.Restore != nil {
+			ui.RestoreSession(database, *ui.Restore)
+			ui.Restore = nil
+		}
+	})
+
 	loadedHeader := "All data is now loaded"
+	if database.TimeZoneError != nil {
+		loadedHeader = database.TimeZoneError.Error()
+	}
 	for i := 0; i < 4; i++ {
 		app.QueueUpdateDraw(func() {
 			ui.SearchTable.SetTitle(loadedHeader).SetTitleAlign(tview.AlignLeft)
@@ -429,6 +1721,7 @@ func (ui *UI) UpdateUncompleteTable(database *Database) {
 	}
 
 	app.QueueUpdateDraw(func() {
-		ui.SearchTable.SetTitle("Stops and their data").SetTitleAlign(tview.AlignCenter)
+		ui.SearchTable.SetTitleAlign(tview.AlignCenter)
+		ui.RepeatSearch()
 	})
 }
@@ -0,0 +1,119 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rivo/tview"
+)
+
+func TestHighlightMatch(t *testing.T) {
+	tests := []struct {
+		text, query, want string
+	}{
+		{ "Rondo Mogilskie", "", "Rondo Mogilskie" },
+		{ "Rondo Mogilskie", "rondo", "[yellow]Rondo[-] Mogilskie" },
+		{ "Rondo Mogilskie", "mog", "Rondo [yellow]Mog[-]ilskie" },
+		{ "Rondo Mogilskie", "=Rondo Mogilskie", "[yellow]Rondo Mogilskie[-]" },
+		{ "Plac [Centralny]", "", "Plac [Centralny[]" },
+		{ "Plac [Centralny]", "plac", "[yellow]Plac[-] [Centralny[]" },
+		{ "[red]Wawel", "wawel", "[red[][yellow]Wawel[-]" },
+	}
+
+	for _, test := range tests {
+		if got := HighlightMatch(test.text, test.query); got != test.want {
+			t.Errorf("HighlightMatch(%q, %q) = %q, want %q", test.text, test.query, got, test.want)
+		}
+	}
+}
+
+func TestKeepSelection(t *testing.T) {
+	connections := func(ids ...int) (result []Connection) {
+		for _, id := range ids {
+			stop := Stop { Id: id, LineNr: id, Direction: "Direction", Name: "Stop" }
+			result = append(result, Connection { Stop: &stop, Minutes: id })
+		}
+		return
+	}
+
+	ui := NewUI()
+	ui.SearchTable = tview.NewTable().SetSelectable(true, false)
+	ui.PopulateSearchTable(connections(1, 2, 3, 4))
+	ui.SearchTable.Select(3, 0)
+
+	// Stop 3 moved a row up
+	ui.KeepSelection(func() {
+		ui.PopulateSearchTable(connections(2, 3, 4, 5))
+	})
+	if row, _ := ui.SearchTable.GetSelection(); row != 2 {
+		t.Errorf("Expected stop 3 selected on row 2, got row %d", row)
+	}
+
+	// Once it's gone the selection stays on the row
+	ui.KeepSelection(func() {
+		ui.PopulateSearchTable(connections(6, 7, 8))
+	})
+	if row, _ := ui.SearchTable.GetSelection(); row != 2 {
+		t.Errorf("Expected row 2 still selected, got row %d", row)
+	}
+
+	ui.KeepSelection(func() {
+		ui.PopulateSearchTable(connections(9))
+	})
+	if row, _ := ui.SearchTable.GetSelection(); row != 1 {
+		t.Errorf("Expected the last row selected, got row %d", row)
+	}
+}
+
+func TestDepartedIsForgottenOutsideTick(t *testing.T) {
+	stop := Stop { Id: 7, LineNr: 52, Direction: "Direction", Name: "Stop" }
+	connections := []Connection { { Stop: &stop, Minutes: 10 } }
+
+	ui := NewUI()
+	ui.SearchTable = tview.NewTable()
+	ui.Departed = map[int]bool { stop.Id: true }
+	ui.Ticking = true
+	ui.PopulateSearchTable(connections)
+	ui.Ticking = false
+	if !ui.Departed[stop.Id] {
+		t.Fatal("Expected the departed stop kept while ticking")
+	}
+
+	ui.PopulateSearchTable(connections)
+	if ui.Departed[stop.Id] {
+		t.Error("Expected the departed stop forgotten by a new search")
+	}
+}
+
+func TestTimesKeepLowFloor(t *testing.T) {
+	setNow(t, time.Date(2020, 6, 1, 8, 5, 0, 0, time.UTC))
+	stops := []Stop {
+		testStop(0, []string { "8" }, []string { "10 20n" }, nil, nil),
+		testStop(1, []string { "8" }, []string { "12 22n" }, nil, nil),
+	}
+	database := &Database { Stops: stops, Status: DatabaseComplete }
+
+	ui := NewUI()
+	ui.CreateTimesPage()
+	ui.CreateStatsPage()
+	ui.RefreshTimesInfo(ConnectionFromStop(stops[0], true))
+
+	// Ctrl+D and the clock refresh the opened stop, Ctrl+N and Ctrl+P open
+	// the next or previous one
+	for _, id := range []int { 0, 1 } {
+		connection := ui.TimesConnection(database, id)
+		if !connection.LowFloor || connection.InfoNext != InfoNextBus(stops[id], true) {
+			t.Errorf("Expected stop %d counted on low-floor departures, got %q", id, connection.InfoNext)
+		}
+
+		ui.RefreshTimesInfo(connection)
+		if next := ui.TimesBanner.GetCell(1, 3).Text; next != InfoNextBus(stops[id], true) {
+			t.Errorf("Expected %q in the banner of stop %d, got %q", InfoNextBus(stops[id], true), id, next)
+		}
+	}
+
+	ui.RefreshTimesInfo(ConnectionFromStop(stops[0], false))
+	if connection := ui.TimesConnection(database, 1); connection.LowFloor {
+		t.Error("Expected every departure counted after opening a stop without low-floor")
+	}
+}
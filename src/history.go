@@ -0,0 +1,185 @@
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How many of the last queries are remembered
+const HistoryLength = 100
+
+type HistoryEntry struct {
+	Time time.Time
+	// Which input it was typed in, "from", "to" or "fuzzy"
+	Field string
+	Query string
+}
+
+// Queries typed in the search fields, oldest first
+type History struct {
+	Entries []HistoryEntry
+}
+
+// State that isn't edited by hand lives apart from the config
+func CreateStatePath(name string) string {
+	path, exists := os.LookupEnv("XDG_STATE_HOME")
+	if exists {
+		return path + "/scheduler/" + name
+	} else {
+		home := os.Getenv("HOME")
+
+		return home + "/.local/state/scheduler/" + name
+	}
+}
+
+// Reads "time;field;query" lines, the time in RFC 3339
+func ReadHistory(path string) (history History, e error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return history, nil
+	} else if err != nil {
+		return history, err
+	}
+
+	for i, line := range strings.Split(string(b), "\n") {
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// NOTE(radomski): The query goes last, so a ";" in it is kept
+		parts := strings.SplitN(line, ";", 3)
+		if len(parts) != 3 {
+			e = fmt.Errorf("%s:%d: expected \"time;field;query\"", path, i + 1)
+			continue
+		}
+
+		at, err := time.Parse(time.RFC3339, parts[0])
+		if err != nil {
+			e = fmt.Errorf("%s:%d: \"%s\" is not a time", path, i + 1, parts[0])
+			continue
+		}
+
+		history.Entries = append(history.Entries, HistoryEntry { at, parts[1], parts[2] })
+	}
+
+	return
+}
+
+func WriteHistory(path string, history History) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	b.WriteString("# time;field;query\n")
+	for _, entry := range history.Entries {
+		fmt.Fprintf(&b, "%s;%s;%s\n", entry.Time.Format(time.RFC3339), entry.Field, entry.Query)
+	}
+
+	return ioutil.WriteFile(path, []byte(b.String()), 0644)
+}
+
+// Remembers the query as the newest one of the field, the same query typed
+// before only moves up
+func (history *History) Add(field, query string, at time.Time) {
+	query = strings.TrimSpace(query)
+	if len(query) == 0 {
+		return
+	}
+
+	entries := history.Entries[:0]
+	for _, entry := range history.Entries {
+		if entry.Field != field || entry.Query != query {
+			entries = append(entries, entry)
+		}
+	}
+
+	entries = append(entries, HistoryEntry { at, field, query })
+	if len(entries) > HistoryLength {
+		entries = entries[len(entries) - HistoryLength:]
+	}
+
+	history.Entries = entries
+}
+
+// Queries of the field, newest first
+func (history History) Queries(field string) (result []string) {
+	for i := len(history.Entries) - 1; i >= 0; i-- {
+		if history.Entries[i].Field == field {
+			result = append(result, history.Entries[i].Query)
+		}
+	}
+
+	return
+}
+
+// Where the interface was left, to come back to it on the next start
+type Session struct {
+	Page string
+	From, To, Fuzzy string
+	// Which of the forms the results came from, "connection" or "fuzzy"
+	Results string
+	// Id of the stop on the selected row of the search table, -1 for none
+	Stop int
+}
+
+// Reads "name;value" lines, the names are those of the `Session` fields
+func ReadSession(path string) (session Session, e error) {
+	session.Stop = -1
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return session, nil
+	} else if err != nil {
+		return session, err
+	}
+
+	fields := map[string]*string {
+		"page": &session.Page,
+		"from": &session.From,
+		"to": &session.To,
+		"fuzzy": &session.Fuzzy,
+		"results": &session.Results,
+	}
+
+	for i, line := range strings.Split(string(b), "\n") {
+		if len(strings.TrimSpace(line)) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.SplitN(line, ";", 2)
+		if len(parts) != 2 {
+			e = fmt.Errorf("%s:%d: expected \"name;value\"", path, i + 1)
+			continue
+		}
+
+		if parts[0] == "stop" {
+			stop, err := strconv.Atoi(parts[1])
+			if err != nil {
+				e = fmt.Errorf("%s:%d: \"%s\" is not a stop id", path, i + 1, parts[1])
+				continue
+			}
+			session.Stop = stop
+		} else if field, present := fields[parts[0]]; present {
+			*field = parts[1]
+		} else {
+			e = fmt.Errorf("%s:%d: unknown name \"%s\"", path, i + 1, parts[0])
+		}
+	}
+
+	return
+}
+
+func WriteSession(path string, session Session) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf("page;%s\nfrom;%s\nto;%s\nfuzzy;%s\nresults;%s\nstop;%d\n",
+		session.Page, session.From, session.To, session.Fuzzy, session.Results, session.Stop)
+	return ioutil.WriteFile(path, []byte(content), 0644)
+}
@@ -0,0 +1,44 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "session")
+	session, err := ReadSession(path)
+	if err != nil || session.Stop != -1 {
+		t.Fatalf("Expected no stop without a session, got %d, %v", session.Stop, err)
+	}
+
+	// Stop 0 is a stop like any other
+	for _, stop := range []int { 0, 1234, -1 } {
+		written := Session {
+			Page: "times",
+			From: "=Wawel", To: "Salwator",
+			Fuzzy: `stop:"=Rondo Mogilskie"`,
+			Results: "connection",
+			Stop: stop,
+		}
+		if err := WriteSession(path, written); err != nil {
+			t.Fatal(err)
+		}
+
+		read, err := ReadSession(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if read != written {
+			t.Errorf("Expected %+v, got %+v", written, read)
+		}
+	}
+}
@@ -0,0 +1,330 @@
+package scheduler
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+type NameMatch struct {
+	Name string
+	Kind MatchKind
+	Score float64
+	// The alias that matched in place of the name, empty when the name did
+	Alias string
+}
+
+// Distinct stop names with the stops carrying them and the bigrams of their
+// words, so a query only looks at the names sharing enough of its letters
+type SearchIndex struct {
+	Names []string
+	// For every name the ids of its stops, which are indexes into the stops
+	StopIds [][]int
+	// Other names of the stops, each pointing at the indexes of the names
+	Aliases []string
+	AliasTargets [][]int
+
+	nameOf map[string]int
+	// NOTE(radomski): Aliases are in the postings after the names, the
+	// alias `i` is `len(Names) + i` there
+	grams map[string][]int
+	// Answers to the last few queries, the typing asks the same ones over
+	// and over, the planner and the interface from different goroutines
+	cache []cachedMatch
+	cacheMutex sync.Mutex
+}
+
+// How many of the last queries keep their answers
+const CachedQueries = 16
+
+type cachedMatch struct {
+	query string
+	// Best first
+	matches []NameMatch
+	byName map[string]NameMatch
+}
+
+var (
+	nameIndex *SearchIndex
+	// The database is loaded in the background while the interface searches
+	nameIndexMutex sync.RWMutex
+)
+
+func SetSearchIndex(index *SearchIndex) {
+	nameIndexMutex.Lock()
+	defer nameIndexMutex.Unlock()
+	nameIndex = index
+}
+
+// The index of the loaded database, nil until there is one
+func CurrentSearchIndex() *SearchIndex {
+	nameIndexMutex.RLock()
+	defer nameIndexMutex.RUnlock()
+	return nameIndex
+}
+
+// Bigrams of the word with its start marked, "^r", "ro", "on", ...
+func wordGrams(word string) (result []string) {
+	runes := append([]rune { '^' }, []rune(word)...)
+	for i := 0; i + 1 < len(runes); i++ {
+		result = append(result, string(runes[i:i + 2]))
+	}
+
+	return
+}
+
+func (index *SearchIndex) addGrams(name string, i int) {
+	for _, word := range strings.FieldsFunc(Normalize(name), isWordSeparator) {
+		for _, gram := range wordGrams(word) {
+			postings := index.grams[gram]
+			if len(postings) == 0 || postings[len(postings) - 1] != i {
+				index.grams[gram] = append(postings, i)
+			}
+		}
+	}
+}
+
+// Aliases map alternative names to the stop names they stand for, the ones
+// pointing at no known stop are left out
+func NewSearchIndex(stops []Stop, aliases map[string][]string) *SearchIndex {
+	index := &SearchIndex {
+		nameOf: make(map[string]int),
+		grams: make(map[string][]int),
+	}
+
+	for _, stop := range stops {
+		i, present := index.nameOf[stop.Name]
+		if !present {
+			i = len(index.Names)
+			index.nameOf[stop.Name] = i
+			index.Names = append(index.Names, stop.Name)
+			index.StopIds = append(index.StopIds, nil)
+			index.addGrams(stop.Name, i)
+		}
+
+		index.StopIds[i] = append(index.StopIds[i], stop.Id)
+	}
+
+	var names []string
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+
+	for _, alias := range names {
+		var targets []int
+		for _, name := range aliases[alias] {
+			if i, present := index.nameOf[name]; present {
+				targets = append(targets, i)
+			}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		index.addGrams(alias, len(index.Names) + len(index.Aliases))
+		index.Aliases = append(index.Aliases, alias)
+		index.AliasTargets = append(index.AliasTargets, targets)
+	}
+
+	return index
+}
+
+// Names sharing at least half of their bigrams with every word of the query,
+// or with the whole of it. All prefix matches do, and typos keep most of them.
+func (index *SearchIndex) candidates(query string) []int {
+	// NOTE(radomski): Only checked against Jaro-Winkler, the other matchers
+	// accept names with fewer of the bigrams and have to look at all of them
+	if _, bigrams := searchMatcher.(JaroWinkler); !bigrams {
+		all := make([]int, len(index.Names) + len(index.Aliases))
+		for i := range all {
+			all[i] = i
+		}
+		return all
+	}
+
+	count := func(grams map[string]bool) (result []int) {
+		counts := make(map[int]int)
+		for gram := range grams {
+			for _, i := range index.grams[gram] {
+				counts[i]++
+			}
+		}
+
+		for i, c := range counts {
+			if c >= (len(grams) + 1) / 2 {
+				result = append(result, i)
+			}
+		}
+		return
+	}
+
+	all := make(map[string]bool)
+	inEvery := make(map[int]int)
+	tokens := strings.FieldsFunc(query, isWordSeparator)
+	for _, token := range tokens {
+		grams := make(map[string]bool)
+		for _, gram := range wordGrams(token) {
+			grams[gram] = true
+			all[gram] = true
+		}
+
+		for _, i := range count(grams) {
+			inEvery[i]++
+		}
+	}
+
+	var result []int
+	for i, c := range inEvery {
+		if c == len(tokens) {
+			result = append(result, i)
+		}
+	}
+
+	if len(tokens) > 1 {
+		for _, i := range count(all) {
+			if inEvery[i] != len(tokens) {
+				result = append(result, i)
+			}
+		}
+	}
+
+	return result
+}
+
+// The answer to the query, moved to the front of the cache, computed when
+// it isn't there
+func (index *SearchIndex) lookup(query string) cachedMatch {
+	query = Normalize(query)
+
+	index.cacheMutex.Lock()
+	for i, cached := range index.cache {
+		if cached.query == query {
+			copy(index.cache[1:i + 1], index.cache[:i])
+			index.cache[0] = cached
+			index.cacheMutex.Unlock()
+			return cached
+		}
+	}
+	index.cacheMutex.Unlock()
+
+	cached := index.match(query)
+
+	index.cacheMutex.Lock()
+	defer index.cacheMutex.Unlock()
+	index.cache = append([]cachedMatch { cached }, index.cache...)
+	if len(index.cache) > CachedQueries {
+		index.cache = index.cache[:CachedQueries]
+	}
+
+	return cached
+}
+
+func (index *SearchIndex) match(query string) cachedMatch {
+	// A name reached both directly and through aliases keeps its best match
+	matched := make(map[string]NameMatch)
+	add := func(match NameMatch) {
+		if previous, present := matched[match.Name]; !present || match.Score > previous.Score {
+			matched[match.Name] = match
+		}
+	}
+
+	for _, i := range index.candidates(query) {
+		if i < len(index.Names) {
+			name := index.Names[i]
+			if kind := MatchKindOf(name, query); kind != NoMatch {
+				add(NameMatch { Name: name, Kind: kind, Score: KindScore(kind, name, query) })
+			}
+			continue
+		}
+
+		alias := index.Aliases[i - len(index.Names)]
+		if kind := MatchKindOf(alias, query); kind != NoMatch {
+			score := KindScore(kind, alias, query)
+			for _, target := range index.AliasTargets[i - len(index.Names)] {
+				add(NameMatch { Name: index.Names[target], Kind: kind, Score: score, Alias: alias })
+			}
+		}
+	}
+
+	result := []NameMatch{}
+	for _, match := range matched {
+		result = append(result, match)
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Score != result[j].Score {
+			return result[i].Score > result[j].Score
+		}
+		return result[i].Name < result[j].Name
+	})
+
+	return cachedMatch { query, result, matched }
+}
+
+// Every name matching the query, best first
+func (index *SearchIndex) Match(query string) []NameMatch {
+	return index.lookup(query).matches
+}
+
+func (index *SearchIndex) Has(name string) bool {
+	_, present := index.nameOf[name]
+	return present
+}
+
+func (index *SearchIndex) Matches(name, query string) bool {
+	_, present := index.lookup(query).byName[name]
+	return present
+}
+
+// The alias through which the name matched the query, empty when it matched
+// by itself or not at all
+func (index *SearchIndex) AliasMatched(name, query string) string {
+	return index.lookup(query).byName[name].Alias
+}
+
+// The stops whose names match the query, best first, `stops` have to be the
+// ones the index was built from
+func (index *SearchIndex) Stops(stops []Stop, query string) (result []Stop) {
+	for _, match := range index.Match(query) {
+		for _, id := range index.StopIds[index.nameOf[match.Name]] {
+			if id < len(stops) && stops[id].Name == match.Name {
+				result = append(result, stops[id])
+			}
+		}
+	}
+
+	return
+}
+
+// At most `count` of the best matching names, each of them once
+func (index *SearchIndex) Suggest(query string, count int) (result []string) {
+	for _, match := range index.Match(query) {
+		if len(result) == count {
+			break
+		}
+		result = append(result, match.Name)
+	}
+
+	return
+}
+
+// The names matching the query only through one of their aliases
+func (index *SearchIndex) AliasMatches(query string) (result []NameMatch) {
+	for _, match := range index.Match(query) {
+		if len(match.Alias) != 0 {
+			result = append(result, match)
+		}
+	}
+
+	return
+}
+
+func AliasMatched(name, query string) string {
+	index := CurrentSearchIndex()
+	if index == nil {
+		return ""
+	}
+
+	return index.AliasMatched(name, query)
+}
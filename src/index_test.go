@@ -0,0 +1,161 @@
+package scheduler
+
+import (
+	"strconv"
+	"testing"
+)
+
+// About as many stop names and routes as Kraków has, every name is a pair of
+// the words below and every route goes through 20 of them
+func benchmarkStops() (stops []Stop) {
+	first := []string {
+		"Rondo", "Plac", "Os.", "Dworzec", "Teatr", "Kopiec", "Nowy", "Stary",
+		"Park", "Most", "Cmentarz", "Szpital", "Brama", "Rynek", "Hala", "Ogród",
+		"Pętla", "Kampus", "Osiedle", "Zajezdnia", "Galeria", "Stadion", "Urząd",
+		"Mogiła", "Wola", "Góra", "Dąbie", "Prądnik", "Łagiewniki", "Bieżanów",
+	}
+	second := []string {
+		"Mogilskie", "Centralny", "Piastów", "Główny", "Bagatela", "Wandy",
+		"Kleparz", "Czyżyny", "Grunwaldzkie", "Kościuszki", "Rżąka", "Salwator",
+		"Wawel", "Starowiślna", "Borkowska", "Północ", "Zachód", "Wschód",
+		"Podgórze", "Krowodrza", "Kurdwanów", "Ruczaj", "Bronowice", "Azory",
+		"Mistrzejowice", "Olsza", "Łęg", "Rakowice", "Swoszowice", "Tyniec",
+		"Wzgórza", "Zabłocie", "Płaszów", "Prokocim", "Wola Justowska",
+		"Bieńczyce", "Nowa Huta", "Kazimierz", "Dębniki", "Zwierzyniec",
+	}
+
+	for lineNr := 1; lineNr <= 150; lineNr++ {
+		for direction := 0; direction < 2; direction++ {
+			for s := 0; s < 20; s++ {
+				i := (lineNr * 7 + s * 13 + direction * 5) % len(first)
+				j := (lineNr * 11 + s * 3 + direction) % len(second)
+				stops = append(stops, Stop {
+					Id: len(stops),
+					LineNr: lineNr,
+					Direction: "Direction " + strconv.Itoa(direction),
+					Name: first[i] + " " + second[j],
+					Times: Times { Hours: []string { "5", "6" }, WorkMins: []string { "10 40", "10 40" } },
+				})
+			}
+		}
+	}
+
+	return
+}
+
+// Typed letter by letter, so every iteration asks about a new query
+var benchmarkQueries = []string {
+	"r", "ro", "ron", "rond", "rondo", "rondo m", "rondo mo", "rondo mog",
+	"d", "dw", "dwo", "dwor", "dworz", "dworzec", "dworzec g", "dworzec gl",
+	"ogrod", "ogrod w", "lagiew", "lagiewniki", "kopeic", "kopiec wnady",
+}
+
+func benchmarkFindConnections(b *testing.B, indexed bool, find func(query string, stops []Stop)) {
+	stops := benchmarkStops()
+	index := CurrentSearchIndex()
+	if indexed {
+		SetSearchIndex(NewSearchIndex(stops, nil))
+	} else {
+		SetSearchIndex(nil)
+	}
+	defer SetSearchIndex(index)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		find(benchmarkQueries[n % len(benchmarkQueries)], stops)
+	}
+}
+
+func findFromTo(query string, stops []Stop) {
+	FindConnections(query, "wawel", stops, NewConstraints("", ""))
+}
+
+func findFrom(query string, stops []Stop) {
+	FindConnectionsOnlyFrom(query, stops, NewConstraints("", ""))
+}
+
+func BenchmarkFindConnectionsLinear(b *testing.B) {
+	benchmarkFindConnections(b, false, findFromTo)
+}
+
+func BenchmarkFindConnectionsIndexed(b *testing.B) {
+	benchmarkFindConnections(b, true, findFromTo)
+}
+
+func BenchmarkFindConnectionsOnlyFromLinear(b *testing.B) {
+	benchmarkFindConnections(b, false, findFrom)
+}
+
+func BenchmarkFindConnectionsOnlyFromIndexed(b *testing.B) {
+	benchmarkFindConnections(b, true, findFrom)
+}
+
+func TestSearchIndexCacheIsBounded(t *testing.T) {
+	index := NewSearchIndex(benchmarkStops(), nil)
+	for _, query := range benchmarkQueries {
+		index.Match(query)
+	}
+
+	if len(index.cache) != CachedQueries {
+		t.Fatalf("Expected %d cached queries, got %d", CachedQueries, len(index.cache))
+	}
+
+	// The newest first, asking again moves a query to the front
+	last := Normalize(benchmarkQueries[len(benchmarkQueries) - 1])
+	if index.cache[0].query != last {
+		t.Errorf("Expected \"%s\" first, got \"%s\"", last, index.cache[0].query)
+	}
+
+	again := Normalize(benchmarkQueries[len(benchmarkQueries) - 5])
+	index.Match(again)
+	if index.cache[0].query != again || len(index.cache) != CachedQueries {
+		t.Errorf("Expected \"%s\" moved to the front of %d, got \"%s\" of %d",
+			again, CachedQueries, index.cache[0].query, len(index.cache))
+	}
+}
+
+func TestSearchIndexMatchesLikeLinearSearch(t *testing.T) {
+	stops := benchmarkStops()
+	index := NewSearchIndex(stops, nil)
+	for _, query := range benchmarkQueries {
+		for _, name := range index.Names {
+			linear := MatchKindOf(name, query) != NoMatch
+			if indexed := index.Matches(name, query); indexed != linear {
+				t.Errorf("\"%s\" for \"%s\": index says %v, linear search %v", name, query, indexed, linear)
+			}
+		}
+	}
+}
+
+// Meant for `go test -race`, the database is loaded while the interface and
+// the planner search
+func TestSearchWhileLoading(t *testing.T) {
+	stops := benchmarkStops()[:200]
+	index := CurrentSearchIndex()
+	defer SetSearchIndex(index)
+	modes := ModeRanges()
+	defer func() {
+		modeRangesMutex.Lock()
+		defer modeRangesMutex.Unlock()
+		modeRanges = modes
+	}()
+	defer SetLowFloorMarkers(LowFloorMarkers())
+
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 20; i++ {
+			SetSearchIndex(NewSearchIndex(stops, nil))
+			SetModeRanges([]ModeRange { { Mode: Tram, From: 0, To: 9 } })
+			SetLowFloorMarkers("n")
+		}
+		done <- true
+	}()
+
+	filter := LineFilter { DenyModes: [ModeCount]bool { NightBus: true } }
+	for i := 0; i < 20; i++ {
+		FindInStops(stops, Query { Text: "rondo" })
+		filter.Stops(stops)
+		IsLowFloor("n")
+	}
+	<-done
+}
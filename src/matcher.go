@@ -0,0 +1,203 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Tells how similar a stop name, or a word of it, is to what was typed, both
+// already normalized
+type Matcher interface {
+	// From 0 for nothing in common to 1 for the same
+	Similarity(name, query string) float64
+	// Least similarity to count as a match, unless the search file sets one
+	Threshold() float64
+	// Indexes of the letters of the name that matched the query
+	Positions(name, query string) []int
+}
+
+type JaroWinkler struct{}
+
+func (JaroWinkler) Similarity(name, query string) float64 {
+	return JWDist(name, query)
+}
+
+func (JaroWinkler) Threshold() float64 {
+	return 0.9
+}
+
+func (JaroWinkler) Positions(name, query string) (result []int) {
+	matches, _, _ := jaroMatches([]rune(name), []rune(query))
+	for i, matched := range matches {
+		if matched {
+			result = append(result, i)
+		}
+	}
+
+	return
+}
+
+// Edits needed to turn one into the other, swapping two neighbouring letters
+// counts as one, compared to the length of the longer one
+type DamerauLevenshtein struct{}
+
+func (DamerauLevenshtein) Similarity(name, query string) float64 {
+	a, b := []rune(name), []rune(query)
+	if len(a) == 0 || len(b) == 0 {
+		return 0.0
+	}
+
+	dist := osaDistances(a, b)
+	return 1.0 - float64(dist[len(a)][len(b)]) / float64(Max(len(a), len(b)))
+}
+
+// Edits needed between every prefix of `a` and every prefix of `b`
+func osaDistances(a, b []rune) [][]int {
+	// NOTE(radomski): The optimal string alignment variant, which never
+	// edits a part of the string twice, is enough for typos
+	dist := make([][]int, len(a) + 1)
+	for i := range dist {
+		dist[i] = make([]int, len(b) + 1)
+		dist[i][0] = i
+	}
+	for j := range dist[0] {
+		dist[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i - 1] == b[j - 1] {
+				cost = 0
+			}
+
+			dist[i][j] = Min(Min(dist[i - 1][j] + 1, dist[i][j - 1] + 1), dist[i - 1][j - 1] + cost)
+			if i > 1 && j > 1 && a[i - 1] == b[j - 2] && a[i - 2] == b[j - 1] {
+				dist[i][j] = Min(dist[i][j], dist[i - 2][j - 2] + 1)
+			}
+		}
+	}
+
+	return dist
+}
+
+func (DamerauLevenshtein) Threshold() float64 {
+	return 0.75
+}
+
+// The letters of the name left in place or swapped by the cheapest edits
+func (DamerauLevenshtein) Positions(name, query string) (result []int) {
+	a, b := []rune(name), []rune(query)
+	dist := osaDistances(a, b)
+
+	for i, j := len(a), len(b); i > 0 && j > 0; {
+		switch {
+		case a[i - 1] == b[j - 1] && dist[i][j] == dist[i - 1][j - 1]:
+			result = append(result, i - 1)
+			i, j = i - 1, j - 1
+		case i > 1 && j > 1 && a[i - 1] == b[j - 2] && a[i - 2] == b[j - 1] &&
+			dist[i][j] == dist[i - 2][j - 2] + 1:
+			result = append(result, i - 1, i - 2)
+			i, j = i - 2, j - 2
+		case dist[i][j] == dist[i - 1][j - 1] + 1:
+			i, j = i - 1, j - 1
+		case dist[i][j] == dist[i - 1][j] + 1:
+			i--
+		default:
+			j--
+		}
+	}
+
+	sort.Ints(result)
+	return
+}
+
+// Like fzf, the typed letters have to appear in the name in the same order,
+// the closer together they are the better, so "rmog" finds "Rondo Mogilskie"
+type Subsequence struct{}
+
+func (Subsequence) Similarity(name, query string) float64 {
+	positions := subsequencePositions([]rune(name), []rune(query))
+	if len(positions) == 0 {
+		return 0.0
+	}
+
+	span := positions[len(positions) - 1] - positions[0] + 1
+	return 0.5 + 0.5 * float64(len(positions)) / float64(span)
+}
+
+func (Subsequence) Threshold() float64 {
+	return 0.6
+}
+
+func (Subsequence) Positions(name, query string) []int {
+	return subsequencePositions([]rune(name), []rune(query))
+}
+
+// Where the letters of the pattern are in the shortest span of the string
+// found going forward, nothing when they aren't all there
+func subsequencePositions(str, pattern []rune) (result []int) {
+	if len(str) == 0 || len(pattern) == 0 {
+		return nil
+	}
+
+	// The first place where the whole pattern is found going forward
+	end, p := -1, 0
+	for i := 0; i < len(str) && p < len(pattern); i++ {
+		if str[i] == pattern[p] {
+			p++
+			end = i
+		}
+	}
+	if p != len(pattern) {
+		return nil
+	}
+
+	// Going back from its end gives the shortest span ending there
+	result = make([]int, len(pattern))
+	for i, p := end, len(pattern) - 1; p >= 0; i-- {
+		if str[i] == pattern[p] {
+			result[p] = i
+			p--
+		}
+	}
+
+	return
+}
+
+var Matchers = map[string]Matcher {
+	"jaro-winkler": JaroWinkler{},
+	"damerau-levenshtein": DamerauLevenshtein{},
+	"subsequence": Subsequence{},
+}
+
+func ParseMatcher(name string) (Matcher, error) {
+	matcher, present := Matchers[strings.TrimSpace(name)]
+	if !present {
+		var names []string
+		for name := range Matchers {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("Unknown matcher \"%s\", expected one of %s", name, strings.Join(names, ", "))
+	}
+
+	return matcher, nil
+}
+
+var searchMatcher Matcher = JaroWinkler{}
+
+func SetMatcher(matcher Matcher) {
+	searchMatcher = matcher
+}
+
+// The threshold from the search file or the flag, otherwise the matcher's own
+func similarityThreshold() float64 {
+	if searchWeights.Threshold != 0 {
+		return searchWeights.Threshold
+	}
+
+	return searchMatcher.Threshold()
+}
@@ -0,0 +1,170 @@
+package scheduler
+
+import (
+	"sort"
+	"testing"
+)
+
+var krakowStopNames = []string {
+	"Rondo Mogilskie", "Rondo Grunwaldzkie", "Rondo Czyżyńskie", "Rondo Matecznego",
+	"Rondo Kocmyrzowskie", "Łagiewniki", "Czyżyny", "Teatr Bagatela", "Teatr Ludowy",
+	"Teatr Słowackiego", "Dworzec Główny", "Dworzec Główny Zachód", "Starowiślna",
+	"Salwator", "Plac Centralny", "Uniwersytet Ekonomiczny", "Kopiec Wandy", "Rżąka",
+	"Wawel", "Krowodrza Górka", "Nowy Kleparz", "Stary Kleparz", "AGH / UR", "Os. Piastów",
+	"Bronowice Małe", "Bronowice", "Mistrzejowice", "Kurdwanów P+R", "Cmentarz Rakowicki",
+	"Poczta Główna", "Filharmonia", "Czerwone Maki P+R", "Nowy Bieżanów P+R",
+	"Plac Inwalidów", "Plac Wszystkich Świętych", "Korona", "Wzgórza Krzesławickie",
+	"Prokocim Szpital", "Dąbie", "Grzegórzki", "Hala Targowa", "Muzeum Narodowe",
+	"Jubilat", "Borek Fałęcki", "Kampus UJ", "Ruczaj", "Norymberska", "Os. Kolorowe",
+	"Kombinat", "Łęgowska",
+}
+
+// What people type when looking for the stops above
+var krakowMisspellings = []struct {
+	query, name string
+}{
+	{ "rondo mogliskie", "Rondo Mogilskie" },
+	{ "rodno mogilskie", "Rondo Mogilskie" },
+	{ "mogilsike", "Rondo Mogilskie" },
+	{ "rondo grunwaldzkei", "Rondo Grunwaldzkie" },
+	{ "rondo czyzynskie", "Rondo Czyżyńskie" },
+	{ "rondo mateczengo", "Rondo Matecznego" },
+	{ "lagiewnki", "Łagiewniki" },
+	{ "łagiewnik", "Łagiewniki" },
+	{ "lagewniki", "Łagiewniki" },
+	{ "czyzny", "Czyżyny" },
+	{ "czyżny", "Czyżyny" },
+	{ "teatr bagatella", "Teatr Bagatela" },
+	{ "bagtela", "Teatr Bagatela" },
+	{ "teatr slowakiego", "Teatr Słowackiego" },
+	{ "dworec glowny", "Dworzec Główny" },
+	{ "dworzec glowyn", "Dworzec Główny" },
+	{ "starowislna", "Starowiślna" },
+	{ "starowiślan", "Starowiślna" },
+	{ "starowisna", "Starowiślna" },
+	{ "salwatr", "Salwator" },
+	{ "plac centalny", "Plac Centralny" },
+	{ "uniwersytet ekonomiczy", "Uniwersytet Ekonomiczny" },
+	{ "kopiec wnady", "Kopiec Wandy" },
+	{ "rzaka", "Rżąka" },
+	{ "wawle", "Wawel" },
+	{ "krowoderza gorka", "Krowodrza Górka" },
+	{ "krowodrza gurka", "Krowodrza Górka" },
+	{ "nowy klepasz", "Nowy Kleparz" },
+	{ "stary klepaz", "Stary Kleparz" },
+	{ "os piastow", "Os. Piastów" },
+	{ "bronowcie male", "Bronowice Małe" },
+	{ "mistrzejowcie", "Mistrzejowice" },
+	{ "mistrzejowice", "Mistrzejowice" },
+	{ "kurdwanow", "Kurdwanów P+R" },
+	{ "cmentarz rakowiecki", "Cmentarz Rakowicki" },
+	{ "poczta glowan", "Poczta Główna" },
+	{ "filharmnoia", "Filharmonia" },
+	{ "czerwone makki", "Czerwone Maki P+R" },
+	{ "nowy biezanow", "Nowy Bieżanów P+R" },
+	{ "plac inwalidow", "Plac Inwalidów" },
+	{ "wszystkich swietych", "Plac Wszystkich Świętych" },
+	{ "wzgorza krzeslawicke", "Wzgórza Krzesławickie" },
+	{ "prokocim szpial", "Prokocim Szpital" },
+	{ "grzegorzki", "Grzegórzki" },
+	{ "grzegozki", "Grzegórzki" },
+	{ "hala tagrowa", "Hala Targowa" },
+	{ "muzuem narodowe", "Muzeum Narodowe" },
+	{ "jubliat", "Jubilat" },
+	{ "borek falecki", "Borek Fałęcki" },
+	{ "borek falencki", "Borek Fałęcki" },
+	{ "norymbeska", "Norymberska" },
+	{ "kombinta", "Kombinat" },
+}
+
+func krakowStops() (stops []Stop) {
+	for i, name := range krakowStopNames {
+		stops = append(stops, Stop { Id: i, LineNr: i, Direction: "Direction", Name: name })
+	}
+
+	return
+}
+
+func useMatcher(t testing.TB, matcher Matcher) {
+	previous := searchMatcher
+	SetMatcher(matcher)
+	t.Cleanup(func() {
+		SetMatcher(previous)
+	})
+}
+
+func matcherNames() (names []string) {
+	for name := range Matchers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return
+}
+
+// How many of the misspellings find the stop meant as the best match, the
+// least is what each matcher managed when the corpus was put together
+func TestMatcherAccuracy(t *testing.T) {
+	least := map[string]int {
+		"jaro-winkler": 50,
+		"damerau-levenshtein": 50,
+		"subsequence": 26,
+	}
+
+	stops := krakowStops()
+	for _, name := range matcherNames() {
+		useMatcher(t, Matchers[name])
+		index := NewSearchIndex(stops, nil)
+
+		found := 0
+		for _, misspelling := range krakowMisspellings {
+			if matches := index.Match(misspelling.query); len(matches) != 0 && matches[0].Name == misspelling.name {
+				found++
+			} else if testing.Verbose() {
+				t.Logf("%s: \"%s\" didn't find %s first", name, misspelling.query, misspelling.name)
+			}
+		}
+
+		t.Logf("%s found %d of %d", name, found, len(krakowMisspellings))
+		if found < least[name] {
+			t.Errorf("%s found %d of %d misspellings, expected at least %d",
+				name, found, len(krakowMisspellings), least[name])
+		}
+	}
+}
+
+func BenchmarkMatchers(b *testing.B) {
+	var names []string
+	for _, name := range krakowStopNames {
+		names = append(names, Normalize(name))
+	}
+
+	for _, name := range matcherNames() {
+		matcher := Matchers[name]
+		b.Run(name, func(b *testing.B) {
+			for n := 0; n < b.N; n++ {
+				query := Normalize(krakowMisspellings[n % len(krakowMisspellings)].query)
+				for _, name := range names {
+					matcher.Similarity(name, query)
+				}
+			}
+		})
+	}
+}
+
+// Only Jaro-Winkler is narrowed down by the bigrams of the index, the others
+// compare every name
+func BenchmarkMatchersThroughIndex(b *testing.B) {
+	stops := benchmarkStops()
+	for _, name := range matcherNames() {
+		b.Run(name, func(b *testing.B) {
+			useMatcher(b, Matchers[name])
+			index := NewSearchIndex(stops, nil)
+
+			b.ResetTimer()
+			for n := 0; n < b.N; n++ {
+				index.Match(benchmarkQueries[n % len(benchmarkQueries)])
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Mode int
+
+const (
+	Tram Mode = iota
+	Bus
+	NightBus
+	AgglomerationBus
+	ModeCount
+)
+
+func (mode Mode) String() string {
+	switch mode {
+	case Tram:
+		return "tram"
+	case Bus:
+		return "bus"
+	case NightBus:
+		return "night"
+	default:
+		return "agglomeration"
+	}
+}
+
+func ParseMode(name string) (Mode, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for mode := Tram; mode < ModeCount; mode++ {
+		if name == mode.String() || name == mode.String() + "s" || name == mode.String() + "es" {
+			return mode, true
+		}
+	}
+
+	return 0, false
+}
+
+// Lines numbered from `From` to `To` inclusive are of the mode
+type ModeRange struct {
+	Mode Mode `json:"mode"`
+	From int `json:"from"`
+	To int `json:"to"`
+}
+
+func (mode *Mode) UnmarshalJSON(b []byte) error {
+	name, err := strconv.Unquote(string(b))
+	if err != nil {
+		return err
+	}
+
+	parsed, ok := ParseMode(name)
+	if !ok {
+		return fmt.Errorf("Unknown transport mode \"%s\"", name)
+	}
+
+	*mode = parsed
+	return nil
+}
+
+// How ZTP numbers its lines, the first range containing a line decides
+var DefaultModeRanges = []ModeRange {
+	{ Mode: NightBus, From: 600, To: 699 },
+	{ Mode: AgglomerationBus, From: 200, To: 399 },
+	{ Mode: Tram, From: 0, To: 99 },
+	{ Mode: Bus, From: 100, To: 999 },
+}
+
+var (
+	modeRanges = DefaultModeRanges
+	// Set when the database is loaded, while the planner may be filtering
+	modeRangesMutex sync.RWMutex
+)
+
+// Ranges from the user come first, then the ones shipped with the database
+func SetModeRanges(ranges ...[]ModeRange) {
+	var all []ModeRange
+	for _, r := range ranges {
+		all = append(all, r...)
+	}
+	all = append(all, DefaultModeRanges...)
+
+	modeRangesMutex.Lock()
+	defer modeRangesMutex.Unlock()
+	modeRanges = all
+}
+
+func ModeRanges() []ModeRange {
+	modeRangesMutex.RLock()
+	defer modeRangesMutex.RUnlock()
+	return modeRanges
+}
+
+// Lines outside of every range are buses
+func ModeOf(lineNr int) Mode {
+	for _, r := range ModeRanges() {
+		if r.From <= lineNr && lineNr <= r.To {
+			return r.Mode
+		}
+	}
+
+	return Bus
+}
+
+func ReadModeRanges(path string) (ranges []ModeRange, e error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		if len(parts) != 3 {
+			e = fmt.Errorf("%s:%d: expected \"mode;from;to\"", path, i + 1)
+			continue
+		}
+
+		mode, ok := ParseMode(parts[0])
+		if !ok {
+			e = fmt.Errorf("%s:%d: unknown transport mode \"%s\"", path, i + 1, parts[0])
+			continue
+		}
+
+		from, err1 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		to, err2 := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err1 != nil || err2 != nil || from > to {
+			e = fmt.Errorf("%s:%d: \"%s;%s\" is not a range of lines", path, i + 1, parts[1], parts[2])
+			continue
+		}
+
+		ranges = append(ranges, ModeRange { Mode: mode, From: from, To: to })
+	}
+
+	return
+}
+
+// Which lines may be used, the zero value allows every one of them
+type LineFilter struct {
+	// When any is set only those modes are allowed
+	Modes [ModeCount]bool
+	// When not empty only these lines are allowed, on top of the modes
+	Lines map[int]bool
+	DenyModes [ModeCount]bool
+	DenyLines map[int]bool
+}
+
+func (filter LineFilter) onlyModes() bool {
+	for _, set := range filter.Modes {
+		if set {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (filter LineFilter) IsEmpty() bool {
+	for mode := Tram; mode < ModeCount; mode++ {
+		if filter.DenyModes[mode] {
+			return false
+		}
+	}
+
+	return !filter.onlyModes() && len(filter.Lines) == 0 && len(filter.DenyLines) == 0
+}
+
+func (filter LineFilter) Allows(lineNr int) bool {
+	mode := ModeOf(lineNr)
+	if filter.DenyLines[lineNr] || filter.DenyModes[mode] {
+		return false
+	}
+
+	if !filter.onlyModes() && len(filter.Lines) == 0 {
+		return true
+	}
+
+	return filter.Modes[mode] || filter.Lines[lineNr]
+}
+
+// Parses comma separated lists of line numbers and modes, like "tram, 139"
+// for what's allowed and "night" for what's not
+func ParseLineFilter(allow, deny string) (filter LineFilter, e error) {
+	parse := func(list string, modes *[ModeCount]bool, lines *map[int]bool) error {
+		for _, item := range strings.Split(list, ",") {
+			item = strings.TrimSpace(item)
+			if len(item) == 0 {
+				continue
+			}
+
+			if mode, ok := ParseMode(item); ok {
+				modes[mode] = true
+			} else if lineNr, err := strconv.Atoi(item); err == nil {
+				if *lines == nil {
+					*lines = make(map[int]bool)
+				}
+				(*lines)[lineNr] = true
+			} else {
+				return fmt.Errorf("\"%s\" is neither a line number nor a mode", item)
+			}
+		}
+
+		return nil
+	}
+
+	if err := parse(allow, &filter.Modes, &filter.Lines); err != nil {
+		return filter, err
+	}
+
+	return filter, parse(deny, &filter.DenyModes, &filter.DenyLines)
+}
+
+// The stops on lines allowed by the filter, routes stay in one piece
+func (filter LineFilter) Stops(stops []Stop) []Stop {
+	if filter.IsEmpty() {
+		return stops
+	}
+
+	var result []Stop
+	for _, stop := range stops {
+		if filter.Allows(stop.LineNr) {
+			result = append(result, stop)
+		}
+	}
+
+	return result
+}
+
+func (filter LineFilter) String() string {
+	var allow, deny []string
+	for mode := Tram; mode < ModeCount; mode++ {
+		if filter.Modes[mode] {
+			allow = append(allow, mode.String())
+		}
+		if filter.DenyModes[mode] {
+			deny = append(deny, "no " + mode.String())
+		}
+	}
+
+	numbers := func(lines map[int]bool, prefix string) (result []string) {
+		var sorted []int
+		for lineNr := range lines {
+			sorted = append(sorted, lineNr)
+		}
+		sort.Ints(sorted)
+
+		for _, lineNr := range sorted {
+			result = append(result, prefix + strconv.Itoa(lineNr))
+		}
+		return
+	}
+
+	allow = append(allow, numbers(filter.Lines, "")...)
+	deny = append(deny, numbers(filter.DenyLines, "no ")...)
+	return strings.Join(append(allow, deny...), ", ")
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func useModeRanges(t *testing.T, ranges ...[]ModeRange) {
+	previous := ModeRanges()
+	SetModeRanges(ranges...)
+	t.Cleanup(func() {
+		modeRangesMutex.Lock()
+		defer modeRangesMutex.Unlock()
+		modeRanges = previous
+	})
+}
+
+func TestModeOf(t *testing.T) {
+	useModeRanges(t)
+
+	// Night and agglomeration buses sit inside of the bus range, the first
+	// range containing a line decides
+	tests := []struct {
+		lineNr int
+		mode Mode
+	}{
+		{ 0, Tram }, { 52, Tram }, { 99, Tram },
+		{ 100, Bus }, { 139, Bus }, { 199, Bus },
+		{ 200, AgglomerationBus }, { 250, AgglomerationBus }, { 399, AgglomerationBus },
+		{ 400, Bus }, { 599, Bus },
+		{ 600, NightBus }, { 601, NightBus }, { 699, NightBus },
+		{ 700, Bus }, { 999, Bus },
+		// Outside of every range
+		{ 1000, Bus }, { -1, Bus },
+	}
+
+	for _, test := range tests {
+		if mode := ModeOf(test.lineNr); mode != test.mode {
+			t.Errorf("ModeOf(%d) = %s, want %s", test.lineNr, mode, test.mode)
+		}
+	}
+}
+
+func TestModeOfOwnRanges(t *testing.T) {
+	user := []ModeRange { { Mode: NightBus, From: 900, To: 999 }, { Mode: Bus, From: 100, To: 105 } }
+	database := []ModeRange { { Mode: Tram, From: 100, To: 110 } }
+	useModeRanges(t, user, database)
+
+	// The user's ranges before the database's and both before the defaults
+	tests := []struct {
+		lineNr int
+		mode Mode
+	}{
+		{ 905, NightBus }, { 103, Bus }, { 107, Tram }, { 111, Bus }, { 601, NightBus }, { 52, Tram },
+	}
+
+	for _, test := range tests {
+		if mode := ModeOf(test.lineNr); mode != test.mode {
+			t.Errorf("ModeOf(%d) = %s, want %s", test.lineNr, mode, test.mode)
+		}
+	}
+}
+
+func TestReadModeRanges(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "modes")
+	if ranges, err := ReadModeRanges(path); ranges != nil || err != nil {
+		t.Fatalf("Expected nothing from a missing file, got %v, %v", ranges, err)
+	}
+
+	content := strings.Join([]string {
+		"# mode;from;to",
+		"trams;0;99",
+		" night ; 900 ; 999 ",
+		"boat;1;2",
+		"bus;5",
+		"bus;9;3",
+		"bus;x;3",
+	}, "\n")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	ranges, err := ReadModeRanges(path)
+	expected := []ModeRange { { Mode: Tram, From: 0, To: 99 }, { Mode: NightBus, From: 900, To: 999 } }
+	if !reflect.DeepEqual(ranges, expected) {
+		t.Errorf("Expected %v, got %v", expected, ranges)
+	}
+	if err == nil {
+		t.Error("Expected an error for the bad lines")
+	}
+}
+
+func TestParseLineFilter(t *testing.T) {
+	useModeRanges(t)
+
+	tests := []struct {
+		allow, deny string
+		allowed, denied []int
+		text string
+	}{
+		{ "", "", []int { 52, 139, 250, 601 }, nil, "" },
+		{ "tram, 139", "", []int { 52, 139 }, []int { 179, 250, 601 }, "tram, 139" },
+		{ "", "night", []int { 52, 179, 250 }, []int { 601 }, "no night" },
+		// Agglomeration buses aren't buses here
+		{ "buses", "139", []int { 179, 999 }, []int { 52, 139, 250, 601 }, "bus, no 139" },
+		{ " , tram ,", "52", []int { 8 }, []int { 52, 179 }, "tram, no 52" },
+		{ "agglomeration", "", []int { 250, 399 }, []int { 199, 400 }, "agglomeration" },
+	}
+
+	for _, test := range tests {
+		filter, err := ParseLineFilter(test.allow, test.deny)
+		if err != nil {
+			t.Errorf("ParseLineFilter(%q, %q): %v", test.allow, test.deny, err)
+			continue
+		}
+
+		for _, lineNr := range test.allowed {
+			if !filter.Allows(lineNr) {
+				t.Errorf("ParseLineFilter(%q, %q) doesn't allow %d", test.allow, test.deny, lineNr)
+			}
+		}
+		for _, lineNr := range test.denied {
+			if filter.Allows(lineNr) {
+				t.Errorf("ParseLineFilter(%q, %q) allows %d", test.allow, test.deny, lineNr)
+			}
+		}
+		if filter.String() != test.text || filter.IsEmpty() != (len(test.text) == 0) {
+			t.Errorf("ParseLineFilter(%q, %q) reads \"%s\", want \"%s\"", test.allow, test.deny, filter, test.text)
+		}
+	}
+
+	errors := []struct {
+		allow, deny string
+	}{
+		{ "tramz", "" },
+		{ "", "5a" },
+		{ "tram; bus", "" },
+		{ "tram", "52 139" },
+	}
+	for _, test := range errors {
+		if _, err := ParseLineFilter(test.allow, test.deny); err == nil {
+			t.Errorf("ParseLineFilter(%q, %q) should fail", test.allow, test.deny)
+		}
+	}
+}
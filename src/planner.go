@@ -0,0 +1,885 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// How many times a journey may change vehicles
+	MaxTransfers = 2
+	// Minutes needed to change vehicles at a stop, which also covers walking
+	// between the stops of the same name, unless the transfers file says otherwise
+	MinTransferTime = 1
+)
+
+const Unreachable = 1 << 30
+
+var timetablesMutex sync.Mutex
+
+// Stops of the same line and direction in the order the vehicles visit them,
+// together with the time every trip calls at each of them (-1 when it doesn't)
+type TimetableRoute struct {
+	LineNr int
+	Direction string
+	Stops []int
+	Trips []*Trip
+	Times [][]int
+}
+
+type routePosition struct {
+	route, position int
+}
+
+// Trips of a single day type arranged for the planner. Places are the
+// distinct stop names, a vehicle can be changed for any other at the same
+// place within its transfer time, or at another place after a footpath.
+type Timetable struct {
+	Day DayType
+	// Set for the night of `Day` continuing into the following day
+	Night bool
+	Stops []Stop
+	Places []string
+	PlaceOf map[string]int
+	StopPlace []int
+	Routes []TimetableRoute
+	RoutesAt [][]routePosition
+
+	TransferTime []int
+	Footpaths [][]Footpath
+}
+
+func NewTimetable(stops []Stop, trips []Trip, rules []TransferRule, day DayType) *Timetable {
+	tt := &Timetable {
+		Day: day,
+		Stops: stops,
+		PlaceOf: make(map[string]int),
+		StopPlace: make([]int, len(stops)),
+	}
+
+	for i, stop := range stops {
+		place, present := tt.PlaceOf[stop.Name]
+		if !present {
+			place = len(tt.Places)
+			tt.PlaceOf[stop.Name] = place
+			tt.Places = append(tt.Places, stop.Name)
+		}
+		tt.StopPlace[i] = place
+	}
+	tt.RoutesAt = make([][]routePosition, len(tt.Places))
+
+	routeOf := make(map[int]int)
+	positionOf := make(map[int]int)
+	for begin := 0; begin < len(stops); {
+		end := begin + 1
+		for end < len(stops) &&
+			stops[end].LineNr == stops[begin].LineNr &&
+			stops[end].Direction == stops[begin].Direction {
+			end++
+		}
+
+		route := TimetableRoute {
+			LineNr: stops[begin].LineNr,
+			Direction: stops[begin].Direction,
+		}
+		for k := begin; k < end; k++ {
+			routeOf[stops[k].Id] = len(tt.Routes)
+			positionOf[stops[k].Id] = k - begin
+			route.Stops = append(route.Stops, k)
+
+			place := tt.StopPlace[k]
+			tt.RoutesAt[place] = append(tt.RoutesAt[place], routePosition { len(tt.Routes), k - begin })
+		}
+
+		tt.Routes = append(tt.Routes, route)
+		begin = end
+	}
+
+	for i := range trips {
+		trip := &trips[i]
+		if trip.Day != day || len(trip.Calls) == 0 {
+			continue
+		}
+
+		route := &tt.Routes[routeOf[trip.Calls[0].StopId]]
+		times := make([]int, len(route.Stops))
+		for p := range times {
+			times[p] = -1
+		}
+		for _, call := range trip.Calls {
+			times[positionOf[call.StopId]] = call.Departure.ServiceMinute()
+		}
+
+		route.Trips = append(route.Trips, trip)
+		route.Times = append(route.Times, times)
+	}
+
+	tt.BuildTransfers(rules)
+	return tt
+}
+
+// The night of `day` followed by the trips of the `following` day type, so a
+// journey started in the small hours can change from a night ride to the
+// first ones of the morning. The following day's trips are copies with their
+// departures marked as `Following`.
+func NewNightTimetable(stops []Stop, trips []Trip, rules []TransferRule, day, following DayType) *Timetable {
+	var night []Trip
+	for _, trip := range trips {
+		if trip.Day == day {
+			night = append(night, trip)
+		}
+	}
+
+	for _, trip := range trips {
+		if trip.Day != following {
+			continue
+		}
+
+		trip.Day = day
+		trip.Calls = append([]TripCall(nil), trip.Calls...)
+		for i := range trip.Calls {
+			trip.Calls[i].Departure.Following = true
+		}
+		night = append(night, trip)
+	}
+
+	tt := NewTimetable(stops, night, rules, day)
+	tt.Night = true
+	return tt
+}
+
+// The timetable to plan in from `at`. Until `ServiceDayStartHour` it's the
+// night of the service day that began the calendar day before.
+func (db *Database) TimetableAt(at time.Time) *Timetable {
+	if at.Hour() >= ServiceDayStartHour {
+		return db.Timetable(DayTypeOf(at))
+	}
+
+	day, following := DayTypeOf(at.AddDate(0, 0, -1)), DayTypeOf(at)
+	if (db.Status & DatabaseComplete) == 0 {
+		return nil
+	}
+
+	timetablesMutex.Lock()
+	defer timetablesMutex.Unlock()
+	if db.nightTimetables[day][following] == nil {
+		db.nightTimetables[day][following] = NewNightTimetable(db.Stops, db.Trips, db.Transfers, day, following)
+	}
+
+	return db.nightTimetables[day][following]
+}
+
+// Builds the timetable for the day type once, nil until the database is loaded
+func (db *Database) Timetable(day DayType) *Timetable {
+	if (db.Status & DatabaseComplete) == 0 {
+		return nil
+	}
+
+	// NOTE(radomski): Journeys are planned in the background, more than one
+	// may ask for the day's timetable before it's built
+	timetablesMutex.Lock()
+	defer timetablesMutex.Unlock()
+	if db.timetables[day] == nil {
+		db.timetables[day] = NewTimetable(db.Stops, db.Trips, db.Transfers, day)
+	}
+
+	return db.timetables[day]
+}
+
+// Places whose name passes the same filter as the From and To fields. The
+// search index already knows the matching names, only without it every place
+// is compared with the query.
+func (tt *Timetable) MatchingPlaces(name string) (result []int) {
+	if pinned, isPinned := PinnedName(name); isPinned {
+		if place, present := tt.PlaceOf[pinned]; present {
+			result = append(result, place)
+		}
+		return
+	}
+
+	if index := CurrentSearchIndex(); index != nil {
+		for _, match := range index.Match(name) {
+			if place, present := tt.PlaceOf[match.Name]; present {
+				result = append(result, place)
+			}
+		}
+		return
+	}
+
+	for place, placeName := range tt.Places {
+		if InputFilter(placeName, name) {
+			result = append(result, place)
+		}
+	}
+
+	return
+}
+
+// Either a ride on a trip or, when `Trip` is nil, a walk between two places
+type Leg struct {
+	Trip *Trip
+	From, To Stop
+	Board, Alight TripCall
+	Departure, Arrival Departure
+	// Minutes spent at `From` before the vehicle leaves
+	Wait int
+}
+
+func (leg Leg) IsWalk() bool {
+	return leg.Trip == nil
+}
+
+func (leg Leg) Minutes() int {
+	return leg.Arrival.ServiceMinute() - leg.Departure.ServiceMinute()
+}
+
+type Journey struct {
+	Legs []Leg
+	// How the journey satisfies the via and avoid constraints
+	Constraints string
+}
+
+func (journey Journey) Rides() (result int) {
+	for _, leg := range journey.Legs {
+		if !leg.IsWalk() {
+			result++
+		}
+	}
+
+	return
+}
+
+func (journey Journey) Walks() int {
+	return len(journey.Legs) - journey.Rides()
+}
+
+func (journey Journey) Transfers() int {
+	return journey.Rides() - 1
+}
+
+func (journey Journey) First() Leg {
+	return journey.Legs[0]
+}
+
+func (journey Journey) Last() Leg {
+	return journey.Legs[len(journey.Legs) - 1]
+}
+
+func (journey Journey) FirstRide() Leg {
+	for _, leg := range journey.Legs {
+		if !leg.IsWalk() {
+			return leg
+		}
+	}
+
+	return journey.First()
+}
+
+// Minutes from leaving the first stop to reaching the last one
+func (journey Journey) Duration(day time.Time) int {
+	return MinutesBetween(journey.First().Departure.Time(day), journey.Last().Arrival.Time(day))
+}
+
+func (journey Journey) Lines() string {
+	var lines []string
+	for _, leg := range journey.Legs {
+		if leg.IsWalk() {
+			lines = append(lines, "walk")
+		} else {
+			lines = append(lines, strconv.Itoa(leg.Trip.LineNr))
+		}
+	}
+
+	return strings.Join(lines, ", ")
+}
+
+func (journey Journey) Path() string {
+	path := journey.First().From.Name
+	for _, leg := range journey.Legs {
+		if leg.IsWalk() {
+			path += fmt.Sprintf(" -> (walk %d min) %s", leg.Minutes(), leg.To.Name)
+		} else {
+			path += " -> " + leg.To.Name
+		}
+	}
+
+	return path
+}
+
+func ServiceMinuteOf(t time.Time) int {
+	hour, min, _ := t.Clock()
+	if hour < ServiceDayStartHour {
+		hour += 24
+	}
+
+	return hour * 60 + min
+}
+
+// Minute of the timetable's service day, which the following day continues
+// in a night timetable
+func (tt *Timetable) MinuteOf(t time.Time) int {
+	minute := ServiceMinuteOf(t)
+	if tt.Night && t.Hour() >= ServiceDayStartHour {
+		minute += 24 * 60
+	}
+
+	return minute
+}
+
+type labelKind int
+
+const (
+	labelNone labelKind = iota
+	labelRide
+	labelWalk
+)
+
+type plannerLabel struct {
+	kind labelKind
+	// Ride on `trip` of `route` between the two positions
+	route, trip int
+	board, alight int
+	// Walk from `from`
+	from, minutes int
+}
+
+type PlanOptions struct {
+	MaxTransfers int
+	// Only change vehicles at stops of the same name
+	NoWalking bool
+	// Minutes after leaving within which everything has to be reached, 0 for no limit
+	Budget int
+	// Places that can't be used nor passed through
+	Avoid map[int]bool
+	// Lines that may be ridden
+	Lines LineFilter
+	// Only trips served by low-floor vehicles may be ridden
+	LowFloor bool
+}
+
+func DefaultPlanOptions() PlanOptions {
+	return PlanOptions {
+		MaxTransfers: MaxTransfers,
+	}
+}
+
+// Earliest arrivals at every place after each round, the labels tell how
+// each of them was reached
+type raptorRun struct {
+	start int
+	arrival [][]int
+	labels [][]plannerLabel
+	best []int
+}
+
+// Fastest journey for each number of rides, as long as it arrives earlier
+// than all of the journeys with fewer rides
+func (tt *Timetable) Plan(origins, targets []int, at time.Time, options PlanOptions) (result []Journey) {
+	run := tt.raptor(origins, targets, at, options)
+
+	for k := 1; k < len(run.labels); k++ {
+		arrive, target := Unreachable, -1
+		for _, place := range targets {
+			if run.labels[k][place].kind != labelNone && run.arrival[k][place] < arrive {
+				arrive, target = run.arrival[k][place], place
+			}
+		}
+
+		if target != -1 {
+			result = append(result, tt.reconstruct(run.labels, k, target, run.start))
+		}
+	}
+
+	return
+}
+
+// RAPTOR (Delling, Pajor, Werneck), every round rides one more vehicle and
+// then walks the footpaths from the stops it reached. Without targets it
+// finds the earliest arrival at every place.
+func (tt *Timetable) raptor(origins, targets []int, at time.Time, options PlanOptions) raptorRun {
+	start := tt.MinuteOf(at)
+	rounds := options.MaxTransfers + 2
+
+	limit := Unreachable
+	if options.Budget > 0 {
+		limit = start + options.Budget
+	}
+
+	arrival := make([][]int, rounds)
+	labels := make([][]plannerLabel, rounds)
+	best := make([]int, len(tt.Places))
+	for k := range arrival {
+		arrival[k] = make([]int, len(tt.Places))
+		labels[k] = make([]plannerLabel, len(tt.Places))
+	}
+	for place := range best {
+		best[place] = Unreachable
+		arrival[0][place] = Unreachable
+	}
+
+	isTarget := make(map[int]bool)
+	for _, place := range targets {
+		isTarget[place] = true
+	}
+
+	bestTarget := func() int {
+		result := limit + 1
+		for place := range isTarget {
+			result = Min(result, best[place])
+		}
+		return result
+	}
+
+	marked := make(map[int]bool)
+	for _, place := range origins {
+		arrival[0][place] = start
+		best[place] = start
+		marked[place] = true
+	}
+
+	walk := func(k int) {
+		if options.NoWalking {
+			return
+		}
+
+		var reached []int
+		for place := range marked {
+			reached = append(reached, place)
+		}
+
+		for _, place := range reached {
+			for _, path := range tt.Footpaths[place] {
+				if options.Avoid[path.To] {
+					continue
+				}
+
+				arrive := arrival[k][place] + path.Minutes
+				if arrive < best[path.To] && arrive < bestTarget() {
+					arrival[k][path.To] = arrive
+					best[path.To] = arrive
+					labels[k][path.To] = plannerLabel { kind: labelWalk, from: place, minutes: path.Minutes }
+					marked[path.To] = true
+				}
+			}
+		}
+	}
+	walk(0)
+
+	for k := 1; k < rounds && len(marked) != 0; k++ {
+		copy(arrival[k], arrival[k - 1])
+
+		// Each route is scanned once, from the earliest marked stop on it
+		queue := make(map[int]int)
+		for place := range marked {
+			for _, rp := range tt.RoutesAt[place] {
+				if !options.Lines.Allows(tt.Routes[rp.route].LineNr) {
+					continue
+				}
+
+				if p, present := queue[rp.route]; !present || rp.position < p {
+					queue[rp.route] = rp.position
+				}
+			}
+		}
+		marked = make(map[int]bool)
+
+		for r, from := range queue {
+			route := &tt.Routes[r]
+			trip, board := -1, -1
+
+			for p := from; p < len(route.Stops); p++ {
+				place := tt.StopPlace[route.Stops[p]]
+				if options.Avoid[place] {
+					trip = -1
+					continue
+				}
+
+				if trip != -1 && route.Times[trip][p] != -1 {
+					arrive := route.Times[trip][p]
+					if arrive < best[place] && arrive < bestTarget() {
+						arrival[k][place] = arrive
+						best[place] = arrive
+						labels[k][place] = plannerLabel { kind: labelRide, route: r, trip: trip, board: board, alight: p }
+						marked[place] = true
+					}
+				}
+
+				ready := arrival[k - 1][place]
+				if ready == Unreachable {
+					continue
+				}
+				if tt.lastLabel(labels, k - 1, place).kind == labelRide {
+					ready += tt.TransferTime[place]
+				}
+
+				current := Unreachable
+				if trip != -1 && route.Times[trip][p] != -1 {
+					current = route.Times[trip][p]
+				}
+
+				for t, times := range route.Times {
+					if options.LowFloor && !route.Trips[t].LowFloor {
+						continue
+					}
+
+					if times[p] >= ready && times[p] < current {
+						current = times[p]
+						trip, board = t, p
+					}
+				}
+			}
+		}
+
+		walk(k)
+	}
+
+	return raptorRun {
+		start: start,
+		arrival: arrival,
+		labels: labels,
+		best: best,
+	}
+}
+
+// The label that decided the arrival at `place` in round `k`
+func (tt *Timetable) lastLabel(labels [][]plannerLabel, k, place int) plannerLabel {
+	for ; k >= 0; k-- {
+		if labels[k][place].kind != labelNone {
+			return labels[k][place]
+		}
+	}
+
+	return plannerLabel{}
+}
+
+// Any stop of the place, walks have to start and end somewhere
+func (tt *Timetable) placeStop(place int) Stop {
+	for i, stopPlace := range tt.StopPlace {
+		if stopPlace == place {
+			return tt.Stops[i]
+		}
+	}
+
+	return Stop { Name: tt.Places[place] }
+}
+
+func (tt *Timetable) reconstruct(labels [][]plannerLabel, k, place, start int) (journey Journey) {
+	// Legs are found from the last one, walks only know their length for now
+	var legs []Leg
+	var walks []int
+
+	for k >= 0 {
+		label := labels[k][place]
+
+		switch label.kind {
+		case labelNone:
+			k--
+			continue
+		case labelWalk:
+			legs = append(legs, Leg {
+				From: tt.placeStop(label.from),
+				To: tt.placeStop(place),
+			})
+			walks = append(walks, label.minutes)
+
+			place = label.from
+		case labelRide:
+			route := &tt.Routes[label.route]
+			from := tt.Stops[route.Stops[label.board]]
+			to := tt.Stops[route.Stops[label.alight]]
+			trip := route.Trips[label.trip]
+			board, _ := trip.CallAt(from.Id)
+			alight, _ := trip.CallAt(to.Id)
+
+			legs = append(legs, Leg {
+				Trip: trip,
+				From: from,
+				To: to,
+				Board: board,
+				Alight: alight,
+				Departure: board.Departure,
+				Arrival: alight.Departure,
+			})
+			walks = append(walks, 0)
+
+			place = tt.StopPlace[route.Stops[label.board]]
+			k--
+		}
+	}
+
+	for i := len(legs) - 1; i >= 0; i-- {
+		journey.Legs = append(journey.Legs, legs[i])
+	}
+
+	// Walks start right after arriving, except for the first one,
+	// which ends just in time for the first ride
+	previous := start
+	for i := range journey.Legs {
+		leg := &journey.Legs[i]
+		if leg.IsWalk() {
+			minutes := walks[len(legs) - 1 - i]
+			begin := previous
+			if i == 0 && len(journey.Legs) > 1 {
+				begin = journey.Legs[1].Departure.ServiceMinute() - minutes
+			}
+
+			leg.Departure = DepartureAtServiceMinute(begin)
+			leg.Arrival = DepartureAtServiceMinute(begin + minutes)
+		} else {
+			leg.Wait = leg.Departure.ServiceMinute() - previous
+		}
+
+		previous = leg.Arrival.ServiceMinute()
+	}
+
+	return
+}
+
+// Journeys between the places matching `from` and `to`, leaving not earlier
+// than `at`. None of them is worse than another in all of the arrival, the
+// number of transfers and the walking time.
+func PlanJourneys(database *Database, from, to string, at time.Time, constraints *Constraints) []Journey {
+	tt := database.TimetableAt(at)
+	if tt == nil {
+		return nil
+	}
+
+	origins := tt.MatchingPlaces(from)
+	targets := tt.MatchingPlaces(to)
+
+	options := DefaultPlanOptions()
+	if constraints != nil {
+		options.Lines = constraints.Lines
+		options.LowFloor = constraints.LowFloor
+	}
+	if constraints != nil && len(constraints.Avoid) != 0 {
+		options.Avoid = make(map[int]bool)
+		for _, place := range tt.MatchingPlaces(constraints.Avoid) {
+			options.Avoid[place] = true
+		}
+	}
+
+	// NOTE(radomski): RAPTOR alone is optimal only in the arrival and the
+	// number of rides, planning once more without footpaths brings in the
+	// journeys that trade a later arrival for no walking
+	plan := func(origins, targets []int, at time.Time) []Journey {
+		journeys := tt.Plan(origins, targets, at, options)
+		noWalking := options
+		noWalking.NoWalking = true
+		return append(journeys, tt.Plan(origins, targets, at, noWalking)...)
+	}
+
+	var journeys []Journey
+	if constraints == nil || len(constraints.Via) == 0 {
+		journeys = plan(origins, targets, at)
+	} else {
+		// Planned in two halves, to the via and on from wherever it was reached
+		for _, first := range ParetoJourneys(plan(origins, tt.MatchingPlaces(constraints.Via), at)) {
+			last := first.Last()
+			via := tt.PlaceOf[last.To.Name]
+			description := fmt.Sprintf("via %s %02d:%02d", last.To.Name, last.Arrival.Hour, last.Arrival.Minute)
+
+			for _, second := range plan([]int { via }, targets, last.Arrival.Time(at)) {
+				journey := JoinJourneys(first, second)
+				if journey.Transfers() <= options.MaxTransfers {
+					journey.Constraints = description
+					journeys = append(journeys, journey)
+				}
+			}
+		}
+	}
+
+	if constraints != nil && len(constraints.Avoid) != 0 {
+		for i := range journeys {
+			if len(journeys[i].Constraints) != 0 {
+				journeys[i].Constraints += ", "
+			}
+			journeys[i].Constraints += "avoids " + constraints.Avoid
+		}
+	}
+
+	return ParetoJourneys(journeys)
+}
+
+// `b` continuing where `a` ends, staying on the same vehicle makes one leg
+func JoinJourneys(a, b Journey) (journey Journey) {
+	journey.Legs = append(journey.Legs, a.Legs...)
+
+	for i, leg := range b.Legs {
+		previous := &journey.Legs[len(journey.Legs) - 1]
+		if i == 0 && !leg.IsWalk() && previous.Trip == leg.Trip {
+			previous.To = leg.To
+			previous.Alight = leg.Alight
+			previous.Arrival = leg.Arrival
+			continue
+		}
+
+		journey.Legs = append(journey.Legs, leg)
+	}
+
+	return
+}
+
+func (journey Journey) WalkingMinutes() (result int) {
+	for _, leg := range journey.Legs {
+		if leg.IsWalk() {
+			result += leg.Minutes()
+		}
+	}
+
+	return
+}
+
+// `a` is at least as good as `b` in every criterion and better in one of them
+func (a Journey) Dominates(b Journey) bool {
+	arrivalA, arrivalB := a.Last().Arrival.ServiceMinute(), b.Last().Arrival.ServiceMinute()
+	if arrivalA > arrivalB || a.Transfers() > b.Transfers() || a.WalkingMinutes() > b.WalkingMinutes() {
+		return false
+	}
+
+	return arrivalA < arrivalB || a.Transfers() < b.Transfers() || a.WalkingMinutes() < b.WalkingMinutes()
+}
+
+func (a Journey) Same(b Journey) bool {
+	return a.First().Departure == b.First().Departure && a.Last().Arrival == b.Last().Arrival &&
+		a.Lines() == b.Lines() && a.Path() == b.Path()
+}
+
+func ParetoJourneys(journeys []Journey) (result []Journey) {
+	for i, journey := range journeys {
+		keep := true
+		for j, other := range journeys {
+			if other.Dominates(journey) || (j < i && other.Same(journey)) {
+				keep = false
+				break
+			}
+		}
+
+		if keep {
+			result = append(result, journey)
+		}
+	}
+
+	return
+}
+
+type JourneyOrder int
+
+const (
+	ByArrival JourneyOrder = iota
+	ByDeparture
+	ByTransfers
+	ByWalking
+	JourneyOrderCount
+)
+
+func (order JourneyOrder) String() string {
+	switch order {
+	case ByArrival:
+		return "arrival"
+	case ByDeparture:
+		return "departure"
+	case ByTransfers:
+		return "transfers"
+	default:
+		return "walking"
+	}
+}
+
+func SortJourneys(journeys []Journey, order JourneyOrder) []Journey {
+	key := func(journey Journey) []int {
+		arrival := journey.Last().Arrival.ServiceMinute()
+		departure := journey.First().Departure.ServiceMinute()
+
+		switch order {
+		case ByDeparture:
+			return []int { departure, arrival }
+		case ByTransfers:
+			return []int { journey.Transfers(), arrival }
+		case ByWalking:
+			return []int { journey.WalkingMinutes(), arrival }
+		default:
+			return []int { arrival, -departure }
+		}
+	}
+
+	sort.SliceStable(journeys, func(i, j int) bool {
+		a, b := key(journeys[i]), key(journeys[j])
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+
+	return journeys
+}
+
+type JourneyFilter int
+
+const (
+	AllJourneys JourneyFilter = iota
+	DirectOnly
+	WithoutWalking
+	JourneyFilterCount
+)
+
+func (filter JourneyFilter) String() string {
+	switch filter {
+	case AllJourneys:
+		return "all"
+	case DirectOnly:
+		return "direct only"
+	default:
+		return "without walking"
+	}
+}
+
+func (filter JourneyFilter) Keep(journey Journey) bool {
+	switch filter {
+	case DirectOnly:
+		return journey.Transfers() == 0 && journey.Walks() == 0
+	case WithoutWalking:
+		return journey.Walks() == 0
+	default:
+		return true
+	}
+}
+
+func FilterJourneys(journeys []Journey, filter JourneyFilter) (result []Journey) {
+	for _, journey := range journeys {
+		if filter.Keep(journey) {
+			result = append(result, journey)
+		}
+	}
+
+	return
+}
+
+func InfoNextJourney(journey Journey) string {
+	now := Now()
+	minNext := MinutesBetween(now, journey.First().Departure.Time(now))
+	arrival := journey.Last().Arrival
+
+	transfers := "direct"
+	switch journey.Transfers() {
+	case 0:
+	case 1:
+		transfers = "1 transfer"
+	default:
+		transfers = fmt.Sprintf("%d transfers", journey.Transfers())
+	}
+
+	info := fmt.Sprintf("[%d min, %s, arrives %02d:%02d]", journey.Duration(now), transfers,
+		arrival.Hour, arrival.Minute)
+	if minNext != 0 {
+		return fmt.Sprintf("In %d min %s", minNext, info)
+	} else {
+		return fmt.Sprintf("Departing right now! %s", info)
+	}
+}
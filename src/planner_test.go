@@ -0,0 +1,269 @@
+package scheduler
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+// Lines 1 from A through B to C, 2 from B to D, 3 from D to E and 4 from F to
+// G, the stop's id is its index. C and F are 230 m apart, 4 minutes of walking.
+func testTimetable(t *testing.T, minTransferTime int) *Timetable {
+	transferTime := MinTransferTime
+	MinTransferTime = minTransferTime
+	t.Cleanup(func() {
+		MinTransferTime = transferTime
+	})
+
+	stop := func(id, lineNr int, name string) Stop {
+		return Stop { Id: id, LineNr: lineNr, Direction: "Line " + strconv.Itoa(lineNr), Name: name }
+	}
+	stops := []Stop {
+		stop(0, 1, "A"), stop(1, 1, "B"), stop(2, 1, "C"),
+		stop(3, 2, "B"), stop(4, 2, "D"),
+		stop(5, 3, "D"), stop(6, 3, "E"),
+		stop(7, 4, "F"), stop(8, 4, "G"),
+	}
+	stops[2].Lat, stops[2].Lon = 50.06, 19.94
+	stops[7].Lat, stops[7].Lon = 50.06 + 230 / 111195.0, 19.94
+
+	trip := func(id, lineNr int, calls ...TripCall) Trip {
+		return Trip { Id: id, LineNr: lineNr, Direction: stops[calls[0].StopId].Direction, Day: WorkDay, Calls: calls }
+	}
+	call := func(stopId, hour, minute int) TripCall {
+		return TripCall { StopId: stopId, Departure: Departure { Hour: hour, Minute: minute } }
+	}
+	trips := []Trip {
+		trip(0, 1, call(0, 8, 0), call(1, 8, 10), call(2, 8, 20)),
+		trip(1, 2, call(3, 8, 11), call(4, 8, 20)),
+		trip(2, 2, call(3, 8, 20), call(4, 8, 30)),
+		trip(3, 3, call(5, 8, 31), call(6, 8, 40)),
+		trip(4, 3, call(5, 8, 45), call(6, 8, 55)),
+		trip(5, 4, call(7, 8, 26), call(8, 8, 35)),
+	}
+
+	return NewTimetable(stops, trips, nil, WorkDay)
+}
+
+func TestPlan(t *testing.T) {
+	at := time.Date(2020, 6, 1, 7, 55, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		from, to string
+		maxTransfers int
+		minTransferTime int
+		// Of the fastest journey, nothing when none is expected
+		lines string
+		arrival string
+	}{
+		{ "direct ride", "A", "C", 2, 1, "1", "08:20" },
+		{ "one transfer", "A", "D", 2, 1, "1, 2", "08:20" },
+		{ "longer transfer misses the first trip", "A", "D", 2, 2, "1, 2", "08:30" },
+		{ "two transfers", "A", "E", 2, 1, "1, 2, 3", "08:40" },
+		{ "longer transfers", "A", "E", 2, 2, "1, 2, 3", "08:55" },
+		{ "too many transfers", "A", "E", 1, 1, "", "" },
+		{ "no transfers allowed", "A", "D", 0, 1, "", "" },
+		{ "against the direction", "C", "A", 2, 1, "", "" },
+		{ "walking between lines", "A", "G", 2, 1, "1, walk, 4", "08:35" },
+		{ "walking is not a transfer", "A", "G", 1, 2, "1, walk, 4", "08:35" },
+	}
+
+	for _, test := range tests {
+		tt := testTimetable(t, test.minTransferTime)
+		options := PlanOptions { MaxTransfers: test.maxTransfers }
+		journeys := tt.Plan([]int { tt.PlaceOf[test.from] }, []int { tt.PlaceOf[test.to] }, at, options)
+
+		if len(test.lines) == 0 {
+			if len(journeys) != 0 {
+				t.Errorf("%s: expected no journey, got %s", test.name, journeys[0].Path())
+			}
+			continue
+		}
+
+		if len(journeys) == 0 {
+			t.Errorf("%s: expected a journey, got none", test.name)
+			continue
+		}
+
+		// The last one has the most rides and arrives the earliest
+		journey := journeys[len(journeys) - 1]
+		last := journey.Last().Arrival
+		arrival := time.Date(0, 1, 1, last.Hour, last.Minute, 0, 0, time.UTC).Format("15:04")
+		if journey.Lines() != test.lines || arrival != test.arrival {
+			t.Errorf("%s: got lines %s arriving %s, want lines %s arriving %s",
+				test.name, journey.Lines(), arrival, test.lines, test.arrival)
+		}
+
+		if journey.Transfers() > test.maxTransfers {
+			t.Errorf("%s: %d transfers, at most %d allowed", test.name, journey.Transfers(), test.maxTransfers)
+		}
+	}
+}
+
+func TestPlanWalkingLeg(t *testing.T) {
+	tt := testTimetable(t, 1)
+	at := time.Date(2020, 6, 1, 7, 55, 0, 0, time.UTC)
+	journeys := tt.Plan([]int { tt.PlaceOf["A"] }, []int { tt.PlaceOf["G"] }, at, DefaultPlanOptions())
+	if len(journeys) != 1 {
+		t.Fatalf("Expected a single journey, got %d", len(journeys))
+	}
+
+	journey := journeys[0]
+	if len(journey.Legs) != 3 || !journey.Legs[1].IsWalk() {
+		t.Fatalf("Expected a ride, a walk and a ride, got %s", journey.Path())
+	}
+
+	walk := journey.Legs[1]
+	if walk.From.Name != "C" || walk.To.Name != "F" || walk.Minutes() != 4 ||
+		walk.Departure.Hour != 8 || walk.Departure.Minute != 20 || journey.WalkingMinutes() != 4 {
+		t.Errorf("Expected a walk from C at 08:20 to F for 4 minutes, got %s to %s at %02d:%02d for %d",
+			walk.From.Name, walk.To.Name, walk.Departure.Hour, walk.Departure.Minute, walk.Minutes())
+	}
+	if journey.Legs[2].Wait != 2 {
+		t.Errorf("Expected a wait of 2 minutes at F, got %d", journey.Legs[2].Wait)
+	}
+
+	options := DefaultPlanOptions()
+	options.NoWalking = true
+	if journeys := tt.Plan([]int { tt.PlaceOf["A"] }, []int { tt.PlaceOf["G"] }, at, options); len(journeys) != 0 {
+		t.Errorf("Expected no journey without walking, got %s", journeys[0].Path())
+	}
+}
+
+func TestPlanWaitsAndTransfers(t *testing.T) {
+	tt := testTimetable(t, 1)
+	at := time.Date(2020, 6, 1, 7, 55, 0, 0, time.UTC)
+	journeys := tt.Plan([]int { tt.PlaceOf["A"] }, []int { tt.PlaceOf["D"] }, at, DefaultPlanOptions())
+	if len(journeys) != 1 {
+		t.Fatalf("Expected a single journey, got %d", len(journeys))
+	}
+
+	legs := journeys[0].Legs
+	if len(legs) != 2 || legs[0].Wait != 5 || legs[1].Wait != 1 {
+		t.Fatalf("Expected waits of 5 and 1 minutes, got %+v", legs)
+	}
+	if legs[0].To.Id != 1 || legs[1].From.Id != 3 {
+		t.Errorf("Expected the change from stop 1 to stop 3 at B, got %d to %d", legs[0].To.Id, legs[1].From.Id)
+	}
+}
+
+// Line 1 from A through B to C and line 2 from B to D. Friday has a night ride
+// of line 1 and an early line 2, Saturday starts with line 2 at 05:00.
+func testNightDatabase() *Database {
+	stop := func(id, lineNr int, name string) Stop {
+		return Stop { Id: id, LineNr: lineNr, Direction: "Line " + strconv.Itoa(lineNr), Name: name }
+	}
+	stops := []Stop {
+		stop(0, 1, "A"), stop(1, 1, "B"), stop(2, 1, "C"),
+		stop(3, 2, "B"), stop(4, 2, "D"),
+	}
+
+	trip := func(id, lineNr int, day DayType, calls ...TripCall) Trip {
+		return Trip { Id: id, LineNr: lineNr, Direction: stops[calls[0].StopId].Direction, Day: day, Calls: calls }
+	}
+	call := func(stopId, hour, minute int) TripCall {
+		return TripCall { StopId: stopId, Departure: Departure { Hour: hour, Minute: minute } }
+	}
+	trips := []Trip {
+		trip(0, 1, WorkDay, call(0, 1, 40), call(1, 1, 50), call(2, 2, 0)),
+		trip(1, 2, WorkDay, call(3, 4, 0), call(4, 4, 10)),
+		trip(2, 2, Saturday, call(3, 5, 0), call(4, 5, 10)),
+	}
+
+	return &Database { Stops: stops, Trips: trips, Status: DatabaseComplete }
+}
+
+func TestPlanAtNight(t *testing.T) {
+	database := testNightDatabase()
+	// Saturday, still Friday's night
+	at := time.Date(2020, 6, 6, 1, 30, 0, 0, time.UTC)
+
+	journeys := PlanJourneys(database, "=A", "=C", at, nil)
+	if len(journeys) != 1 || journeys[0].Lines() != "1" {
+		t.Fatalf("Expected Friday's night ride of line 1, got %d journeys", len(journeys))
+	}
+
+	// Changing from the night ride to Saturday's first line 2, not to
+	// Friday's one that left the morning before
+	journeys = PlanJourneys(database, "=A", "=D", at, nil)
+	if len(journeys) != 1 {
+		t.Fatalf("Expected a single journey, got %d", len(journeys))
+	}
+
+	journey := journeys[0]
+	departure := journey.First().Departure.Time(at)
+	arrival := journey.Last().Arrival.Time(at)
+	if journey.Lines() != "1, 2" ||
+		!departure.Equal(time.Date(2020, 6, 6, 1, 40, 0, 0, time.UTC)) ||
+		!arrival.Equal(time.Date(2020, 6, 6, 5, 10, 0, 0, time.UTC)) {
+		t.Errorf("Expected lines 1, 2 from 01:40 to 05:10, got %s from %v to %v", journey.Lines(), departure, arrival)
+	}
+	if legs := journey.Legs; legs[1].Wait != 190 || journey.Duration(at) != 210 {
+		t.Errorf("Expected a wait of 190 and 210 minutes in all, got %d and %d", legs[1].Wait, journey.Duration(at))
+	}
+
+	// Once the night is over, it's Saturday's timetable alone
+	at = time.Date(2020, 6, 6, 4, 30, 0, 0, time.UTC)
+	journeys = PlanJourneys(database, "=B", "=D", at, nil)
+	if len(journeys) != 1 || journeys[0].First().Departure.Hour != 5 {
+		t.Errorf("Expected Saturday's line 2 at 05:00, got %d journeys", len(journeys))
+	}
+}
+
+func TestDepartureTimeOfFollowingDay(t *testing.T) {
+	tests := []struct {
+		day time.Time
+		dep Departure
+		want time.Time
+	}{
+		{ time.Date(2020, 6, 6, 1, 30, 0, 0, time.UTC), Departure { Hour: 5, Minute: 10, Following: true },
+			time.Date(2020, 6, 6, 5, 10, 0, 0, time.UTC) },
+		{ time.Date(2020, 6, 6, 1, 30, 0, 0, time.UTC), Departure { Hour: 1, Minute: 10, Following: true },
+			time.Date(2020, 6, 7, 1, 10, 0, 0, time.UTC) },
+		{ time.Date(2020, 6, 6, 3, 30, 0, 0, time.UTC), Departure { Hour: 5, Minute: 10, Following: true },
+			time.Date(2020, 6, 7, 5, 10, 0, 0, time.UTC) },
+		{ time.Date(2020, 6, 6, 1, 30, 0, 0, time.UTC), Departure { Hour: 1, Minute: 50 },
+			time.Date(2020, 6, 6, 1, 50, 0, 0, time.UTC) },
+	}
+
+	for _, test := range tests {
+		if got := test.dep.Time(test.day); !got.Equal(test.want) {
+			t.Errorf("%+v on %v: expected %v, got %v", test.dep, test.day, test.want, got)
+		}
+
+		minute := test.dep.ServiceMinute()
+		if back := DepartureAtServiceMinute(minute); back.ServiceMinute() != minute {
+			t.Errorf("Service minute %d came back as %d", minute, back.ServiceMinute())
+		}
+	}
+}
+
+func TestMatchingPlacesThroughIndex(t *testing.T) {
+	stops := benchmarkStops()
+	tt := NewTimetable(stops, nil, nil, WorkDay)
+	queries := append([]string { "", "=Rondo Mogilskie", "=Nowhere" }, benchmarkQueries...)
+
+	index := CurrentSearchIndex()
+	defer SetSearchIndex(index)
+
+	for _, query := range queries {
+		SetSearchIndex(nil)
+		linear := make(map[int]bool)
+		for _, place := range tt.MatchingPlaces(query) {
+			linear[place] = true
+		}
+
+		SetSearchIndex(NewSearchIndex(stops, nil))
+		indexed := tt.MatchingPlaces(query)
+		if len(indexed) != len(linear) {
+			t.Errorf("\"%s\": %d places through the index, %d without it", query, len(indexed), len(linear))
+			continue
+		}
+		for _, place := range indexed {
+			if !linear[place] {
+				t.Errorf("\"%s\": %s only matched through the index", query, tt.Places[place])
+			}
+		}
+	}
+}
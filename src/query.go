@@ -0,0 +1,236 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// What the fuzzy search box asks for, like
+// `line:52 dir:Czyżyny stop:"Rondo Mogilskie" within:15m day:saturday`.
+// Words without a key are searched for like before there were keys.
+type Query struct {
+	Text string
+	Stop string
+	Direction string
+	Lines []int
+	// Minutes within which the next departure has to leave, 0 for any
+	Within int
+	Day DayType
+	HasDay bool
+	LowFloor bool
+}
+
+// The part of the typed text that makes no sense
+type QueryError struct {
+	Token string
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("%s \"%s\"", e.Message, e.Token)
+}
+
+func ParseDayType(name string) (DayType, bool) {
+	switch Normalize(name) {
+	case "work", "workday", "weekday":
+		return WorkDay, true
+	case "saturday", "sat":
+		return Saturday, true
+	case "holiday", "sunday", "sun":
+		return Holiday, true
+	}
+
+	return 0, false
+}
+
+// Minutes as "15", "15m", "15min" or "1h"
+func ParseMinutes(value string) (int, bool) {
+	multiplier := 1
+	switch {
+	case strings.HasSuffix(value, "min"):
+		value = strings.TrimSuffix(value, "min")
+	case strings.HasSuffix(value, "m"):
+		value = strings.TrimSuffix(value, "m")
+	case strings.HasSuffix(value, "h"):
+		value = strings.TrimSuffix(value, "h")
+		multiplier = 60
+	}
+
+	minutes, err := strconv.Atoi(value)
+	if err != nil || minutes <= 0 {
+		return 0, false
+	}
+
+	return minutes * multiplier, true
+}
+
+type queryToken struct {
+	text string
+	key, value string
+}
+
+// Splits on spaces outside of double quotes, `key:"a b"` stays one token
+func tokenizeQuery(text string) (tokens []queryToken, e error) {
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+
+		start := i
+		quoted := false
+		var value []rune
+		for ; i < len(runes) && (quoted || !unicode.IsSpace(runes[i])); i++ {
+			if runes[i] == '"' {
+				quoted = !quoted
+				continue
+			}
+			value = append(value, runes[i])
+		}
+
+		token := queryToken { text: string(runes[start:i]) }
+		if quoted {
+			return nil, &QueryError { Token: token.text, Message: "Missing closing quote in" }
+		}
+
+		// NOTE(radomski): Only letters before the colon make a key, so that
+		// "10:30" or a stop name with a colon is still plain text
+		if colon := strings.IndexRune(string(value), ':'); colon > 0 &&
+			strings.IndexFunc(string(value)[:colon], func(r rune) bool { return !unicode.IsLetter(r) }) == -1 {
+			token.key = strings.ToLower(string(value)[:colon])
+			token.value = string(value)[colon + 1:]
+		} else {
+			token.value = string(value)
+		}
+
+		tokens = append(tokens, token)
+	}
+
+	return
+}
+
+func ParseQuery(text string) (query Query, e error) {
+	tokens, err := tokenizeQuery(text)
+	if err != nil {
+		return query, err
+	}
+
+	var words []string
+	within := ""
+	for _, token := range tokens {
+		fail := func(message string) error {
+			return &QueryError { Token: token.text, Message: message }
+		}
+
+		if len(token.key) != 0 && len(token.value) == 0 {
+			return query, fail("Missing value in")
+		}
+
+		switch token.key {
+		case "":
+			words = append(words, token.value)
+		case "line":
+			for _, number := range strings.Split(token.value, ",") {
+				lineNr, err := strconv.Atoi(number)
+				if err != nil {
+					return query, fail("Not a line number in")
+				}
+				query.Lines = append(query.Lines, lineNr)
+			}
+		case "dir":
+			query.Direction = token.value
+		case "stop":
+			query.Stop = token.value
+		case "within":
+			minutes, ok := ParseMinutes(token.value)
+			if !ok {
+				return query, fail("Expected minutes like 15m in")
+			}
+			query.Within, within = minutes, token.text
+		case "day":
+			day, ok := ParseDayType(token.value)
+			if !ok {
+				return query, fail("Expected work, saturday or holiday in")
+			}
+			query.Day, query.HasDay = day, true
+		default:
+			return query, fail("Unknown key")
+		}
+	}
+
+	if query.Within != 0 && query.HasDay {
+		return query, &QueryError { Token: within, Message: "Can't be used with day: in" }
+	}
+
+	// NOTE(radomski): Only a number by itself is a line, looked up as the
+	// start of the line numbers. Among other words it's a part of the name,
+	// there are stops like "Os. Kolorowe 2", lines are given with `line:`.
+	query.Text = strings.Join(words, " ")
+	return
+}
+
+func isNumber(word string) bool {
+	return len(word) != 0 && strings.IndexFunc(word, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	}) == -1
+}
+
+func (query Query) IsEmpty() bool {
+	return len(query.Text) == 0 && len(query.Stop) == 0 && len(query.Direction) == 0 &&
+		len(query.Lines) == 0 && query.Within == 0 && !query.HasDay
+}
+
+// The stop name searched for, the plain words count as a part of it unless
+// they are a line number
+func (query Query) Name() string {
+	if query.IsLineNumber() {
+		return ""
+	}
+
+	return strings.TrimSpace(query.Stop + " " + query.Text)
+}
+
+// A number typed alone, which finds the lines starting with it, next to a
+// stop or lines it's a part of the name
+func (query Query) IsLineNumber() bool {
+	return len(query.Stop) == 0 && len(query.Lines) == 0 && isNumber(query.Text)
+}
+
+// Everything except for the name, which decides the order instead
+func (query Query) Keeps(stop Stop) bool {
+	if len(query.Lines) != 0 {
+		found := false
+		for _, lineNr := range query.Lines {
+			found = found || lineNr == stop.LineNr
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(query.Direction) != 0 && !InputFilter(stop.Direction, query.Direction) {
+		return false
+	}
+
+	if query.HasDay {
+		runs := false
+		for _, mins := range stop.Times.Mins(query.Day) {
+			runs = runs || len(strings.TrimSpace(mins)) != 0
+		}
+		if !runs {
+			return false
+		}
+	}
+
+	if query.Within != 0 {
+		minutes := MinsToNextBus(stop, query.LowFloor)
+		if minutes < 0 || minutes > query.Within {
+			return false
+		}
+	}
+
+	return true
+}
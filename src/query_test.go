@@ -0,0 +1,79 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		text string
+		want Query
+	}{
+		{ "rondo mogilskie", Query { Text: "rondo mogilskie" } },
+		{ "52", Query { Text: "52" } },
+		{ "52 dir:czyzyny", Query { Text: "52", Direction: "czyzyny" } },
+		{ "rondo line:52", Query { Text: "rondo", Lines: []int { 52 } } },
+		{ "Os. Kolorowe 2", Query { Text: "Os. Kolorowe 2" } },
+		{ "Rondo 4", Query { Text: "Rondo 4" } },
+		{ "line:8 52", Query { Text: "52", Lines: []int { 8 } } },
+		{ `stop:"Os. Kolorowe 2"`, Query { Stop: "Os. Kolorowe 2" } },
+		{ `stop:"Rondo Mogilskie" 52`, Query { Text: "52", Stop: "Rondo Mogilskie" } },
+		{ "dir:czyzyny within:15m", Query { Direction: "czyzyny", Within: 15 } },
+		{ "dir:czyzyny day:sat", Query { Direction: "czyzyny", Day: Saturday, HasDay: true } },
+	}
+
+	for _, test := range tests {
+		got, err := ParseQuery(test.text)
+		if err != nil {
+			t.Errorf("ParseQuery(%q) failed: %v", test.text, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParseQuery(%q) = %+v, want %+v", test.text, got, test.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	tests := []struct {
+		text, token string
+	}{
+		{ `stop:"Rondo`, `stop:"Rondo` },
+		{ "line:5x", "line:5x" },
+		{ "foo:1", "foo:1" },
+		{ "within:", "within:" },
+		{ "day:sat within:15m", "within:15m" },
+		{ "within:1h rondo day:holiday", "within:1h" },
+	}
+
+	for _, test := range tests {
+		_, err := ParseQuery(test.text)
+		if queryErr, ok := err.(*QueryError); !ok || queryErr.Token != test.token {
+			t.Errorf("ParseQuery(%q) = %v, want an error about %q", test.text, err, test.token)
+		}
+	}
+}
+
+func TestQueryName(t *testing.T) {
+	tests := []struct {
+		text, name string
+		lineNumber bool
+	}{
+		{ "52", "", true },
+		{ "rondo 4", "rondo 4", false },
+		{ "rondo line:52", "rondo", false },
+		{ "line:8 52", "52", false },
+		{ `stop:rondo mog`, "rondo mog", false },
+		{ `stop:"Os. Kolorowe" 2`, "Os. Kolorowe 2", false },
+	}
+
+	for _, test := range tests {
+		query, _ := ParseQuery(test.text)
+		if query.Name() != test.name || query.IsLineNumber() != test.lineNumber {
+			t.Errorf("ParseQuery(%q) has the name %q and a line number %v, want %q and %v",
+				test.text, query.Name(), query.IsLineNumber(), test.name, test.lineNumber)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package scheduler
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// A stop that can be reached from the origin within the budget
+type Reachable struct {
+	Name string
+	Arrival Departure
+	Minutes int
+	Journey Journey
+}
+
+// Every place reachable from `origins` leaving at `at`, within the options' budget
+func (tt *Timetable) Reach(origins []int, at time.Time, options PlanOptions) (result []Reachable) {
+	run := tt.raptor(origins, nil, at, options)
+
+	isOrigin := make(map[int]bool)
+	for _, place := range origins {
+		isOrigin[place] = true
+	}
+
+	for place, arrive := range run.best {
+		if arrive == Unreachable || isOrigin[place] {
+			continue
+		}
+
+		journey := tt.reconstruct(run.labels, len(run.labels) - 1, place, run.start)
+		if len(journey.Legs) == 0 {
+			continue
+		}
+
+		result = append(result, Reachable {
+			Name: tt.Places[place],
+			Arrival: DepartureAtServiceMinute(arrive),
+			Minutes: arrive - run.start,
+			Journey: journey,
+		})
+	}
+
+	return SortReachable(result, ByTravelTime)
+}
+
+func ReachableStops(database *Database, from string, at time.Time, budget int, lines LineFilter) []Reachable {
+	tt := database.TimetableAt(at)
+	if tt == nil {
+		return nil
+	}
+
+	options := DefaultPlanOptions()
+	options.Budget = budget
+	options.Lines = lines
+	return tt.Reach(tt.MatchingPlaces(from), at, options)
+}
+
+type ReachableOrder int
+
+const (
+	ByTravelTime ReachableOrder = iota
+	ByStopName
+	ByReachTransfers
+	ReachableOrderCount
+)
+
+func (order ReachableOrder) String() string {
+	switch order {
+	case ByTravelTime:
+		return "travel time"
+	case ByStopName:
+		return "stop name"
+	default:
+		return "transfers"
+	}
+}
+
+func SortReachable(reachable []Reachable, order ReachableOrder) []Reachable {
+	sort.SliceStable(reachable, func(i, j int) bool {
+		a, b := reachable[i], reachable[j]
+		switch order {
+		case ByStopName:
+			return a.Name < b.Name
+		case ByReachTransfers:
+			if a.Journey.Transfers() != b.Journey.Transfers() {
+				return a.Journey.Transfers() < b.Journey.Transfers()
+			}
+		}
+
+		if a.Minutes != b.Minutes {
+			return a.Minutes < b.Minutes
+		}
+		return a.Name < b.Name
+	})
+
+	return reachable
+}
+
+const ReachableHeaders = "Stop name;Arrival;Minutes;Transfers;Walking;Lines;Route"
+
+func (r Reachable) Columns() []string {
+	return []string {
+		r.Name,
+		fmt.Sprintf("%02d:%02d", r.Arrival.Hour, r.Arrival.Minute),
+		strconv.Itoa(r.Minutes),
+		strconv.Itoa(r.Journey.Transfers()),
+		strconv.Itoa(r.Journey.WalkingMinutes()),
+		r.Journey.Lines(),
+		r.Journey.Path(),
+	}
+}
+
+func WriteReachableCSV(w io.Writer, reachable []Reachable) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string { "stop", "arrival", "minutes", "transfers", "walking", "lines", "route" })
+	for _, r := range reachable {
+		cw.Write(r.Columns())
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func WriteReachableJSON(w io.Writer, reachable []Reachable) error {
+	type entry struct {
+		Stop string `json:"stop"`
+		Arrival string `json:"arrival"`
+		Minutes int `json:"minutes"`
+		Transfers int `json:"transfers"`
+		Walking int `json:"walking"`
+		Lines string `json:"lines"`
+		Route string `json:"route"`
+	}
+
+	entries := []entry{}
+	for _, r := range reachable {
+		entries = append(entries, entry {
+			Stop: r.Name,
+			Arrival: fmt.Sprintf("%02d:%02d", r.Arrival.Hour, r.Arrival.Minute),
+			Minutes: r.Minutes,
+			Transfers: r.Journey.Transfers(),
+			Walking: r.Journey.WalkingMinutes(),
+			Lines: r.Journey.Lines(),
+			Route: r.Journey.Path(),
+		})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
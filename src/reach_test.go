@@ -0,0 +1,89 @@
+package scheduler
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReach(t *testing.T) {
+	tt := testTimetable(t, 1)
+	at := time.Date(2020, 6, 1, 7, 55, 0, 0, time.UTC)
+	options := DefaultPlanOptions()
+	options.Budget = 30
+
+	// E at 08:40 and G at 08:35 are out of the 30 minutes
+	reachable := tt.Reach([]int { tt.PlaceOf["A"] }, at, options)
+	expected := []struct {
+		name, arrival string
+		minutes int
+	}{
+		{ "B", "08:10", 15 },
+		{ "C", "08:20", 25 },
+		{ "D", "08:20", 25 },
+		{ "F", "08:24", 29 },
+	}
+	if len(reachable) != len(expected) {
+		t.Fatalf("Expected %d reachable stops, got %d", len(expected), len(reachable))
+	}
+	for i, want := range expected {
+		got := reachable[i]
+		if got.Name != want.name || got.Columns()[1] != want.arrival || got.Minutes != want.minutes {
+			t.Errorf("Expected %s at %s in %d minutes, got %s at %s in %d",
+				want.name, want.arrival, want.minutes, got.Name, got.Columns()[1], got.Minutes)
+		}
+	}
+
+	options.Budget = 24
+	if reachable := tt.Reach([]int { tt.PlaceOf["A"] }, at, options); len(reachable) != 1 {
+		t.Errorf("Expected only B within 24 minutes, got %d stops", len(reachable))
+	}
+}
+
+func TestWriteReachable(t *testing.T) {
+	tt := testTimetable(t, 1)
+	at := time.Date(2020, 6, 1, 7, 55, 0, 0, time.UTC)
+	options := DefaultPlanOptions()
+	options.Budget = 30
+	reachable := tt.Reach([]int { tt.PlaceOf["A"] }, at, options)
+
+	var csv bytes.Buffer
+	if err := WriteReachableCSV(&csv, reachable); err != nil {
+		t.Fatal(err)
+	}
+	rows := strings.Join([]string {
+		"stop,arrival,minutes,transfers,walking,lines,route",
+		"B,08:10,15,0,0,1,A -> B",
+		"C,08:20,25,0,0,1,A -> C",
+		"D,08:20,25,1,0,\"1, 2\",A -> B -> D",
+		"F,08:24,29,0,4,\"1, walk\",A -> C -> (walk 4 min) F",
+	}, "\n") + "\n"
+	if csv.String() != rows {
+		t.Errorf("Expected the CSV\n%s\ngot\n%s", rows, csv.String())
+	}
+
+	var out bytes.Buffer
+	if err := WriteReachableJSON(&out, reachable); err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(reachable) {
+		t.Fatalf("Expected %d entries, got %d", len(reachable), len(entries))
+	}
+	last := entries[len(entries) - 1]
+	if last["stop"] != "F" || last["arrival"] != "08:24" || last["minutes"] != 29.0 ||
+		last["walking"] != 4.0 || last["lines"] != "1, walk" {
+		t.Errorf("Expected F at 08:24 after walking 4 minutes, got %v", last)
+	}
+
+	// Nothing reachable is still a list
+	out.Reset()
+	if err := WriteReachableJSON(&out, nil); err != nil || strings.TrimSpace(out.String()) != "[]" {
+		t.Errorf("Expected an empty list, got %q, %v", out.String(), err)
+	}
+}
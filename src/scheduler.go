@@ -1,23 +1,43 @@
 package scheduler
 
 import (
+	"flag"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 	"unicode"
 	"sort"
+	"sync"
 
 	"github.com/rivo/tview"
 )
 
 var (
 	app *tview.Application = tview.NewApplication()
+
+	// Time zone in which the schedule's hours and minutes are written, every
+	// query about "now" has to be converted into it before looking at the clock
+	scheduleLocation *time.Location = time.Local
+	// Loading the database sets the zone while the interface already asks for it
+	scheduleLocationMutex sync.RWMutex
 )
 
 type Connection struct {
 	Stop *Stop
 	Path, InfoNext string
+	// Set when getting there needs more than one vehicle
+	Journey *Journey
+	// How the connection satisfies the via and avoid constraints
+	Constraints string
+	// Set when the stop was found by one of its other names
+	Alias string
+	// The stops of a direct ride, from boarding to getting off
+	Ride []Stop
+	LowFloor bool
+	// Until it leaves, `BeyondSchedule` or `NotWorkDays` when it doesn't
+	Minutes int
 
 	// NOTE(radomski): See comment in `FindConnections`
 	// CommuteLength, MinutesUntilNext string
@@ -30,29 +50,104 @@ type SearchEntry struct {
 	InfoNext string
 }
 
-func ConnectionFromStop(stop Stop) (result Connection) {
+func ConnectionFromStop(stop Stop, lowFloor bool) (result Connection) {
 	return Connection {
 		Stop: &stop,
-		InfoNext: InfoNextBus(stop),
+		InfoNext: InfoNextBus(stop, lowFloor),
+		LowFloor: lowFloor,
+		Minutes: MinsToNextBus(stop, lowFloor),
 	}
+}
 
-	return 
+func ConnectionFromJourney(journey Journey) Connection {
+	first := journey.FirstRide().From
+	return Connection {
+		Stop: &first,
+		Path: journey.Path(),
+		InfoNext: InfoNextJourney(journey),
+		Journey: &journey,
+		Constraints: journey.Constraints,
+		Minutes: MinutesBetween(Now(), journey.First().Departure.Time(Now())),
+	}
+}
+
+// What a connection is found by again once the rows are recomputed
+type ConnectionKey struct {
+	StopId int
+	// Of the first ride of a journey, it's another journey once that leaves
+	LineNr int
+	Departure Departure
+}
+
+func (connection Connection) Key() ConnectionKey {
+	key := ConnectionKey { StopId: connection.Stop.Id }
+	if connection.Journey != nil {
+		ride := connection.Journey.FirstRide()
+		if ride.Trip != nil {
+			key.LineNr = ride.Trip.LineNr
+		}
+		key.Departure = ride.Departure
+	}
+
+	return key
+}
+
+// The journey to show in detail, false for a stop that isn't a ride anywhere
+func (connection Connection) Detail() (Journey, bool) {
+	if connection.Journey != nil {
+		return *connection.Journey, true
+	} else if len(connection.Ride) > 1 {
+		return JourneyOnRide(connection.Ride, connection.LowFloor)
+	}
+
+	return Journey{}, false
+}
+
+func (connection Connection) Lines() string {
+	if connection.Journey != nil {
+		return connection.Journey.Lines()
+	}
+
+	return strconv.Itoa(connection.Stop.LineNr)
 }
 
-func ConnectionsFromStops(stops []Stop) (result []Connection) {
+func ConnectionsFromStops(stops []Stop, lowFloor bool) (result []Connection) {
 	for _, stop := range stops {
-		result = append(result, ConnectionFromStop(stop))
+		result = append(result, ConnectionFromStop(stop, lowFloor))
 	}
 
 	return
 }
 
+// Marks a name picked from the suggestions, it then matches only the stops
+// with exactly that name instead of the look-alike ones. It can be typed in
+// front of a name as well.
+const PinMarker = "="
+
+func PinName(name string) string {
+	return PinMarker + name
+}
+
+func PinnedName(s string) (string, bool) {
+	if strings.HasPrefix(s, PinMarker) {
+		return strings.TrimPrefix(s, PinMarker), true
+	}
+
+	return "", false
+}
+
 func InputFilter(main, s string) bool {
-	// Both of this calls are case insensitive, I don't really know if
+	if name, pinned := PinnedName(s); pinned {
+		return main == name
+	}
+
+	// Case and diacritics are ignored, I don't really know if
 	// someone would ever want it to be case sensitive.
-	const treshold float64 = 0.9
-	return IsFuzzyEqualInsens(main, s, treshold) ||
-		strings.HasPrefix(strings.ToLower(main), strings.ToLower(s))
+	if index := CurrentSearchIndex(); index != nil && index.Has(main) {
+		return index.Matches(main, s)
+	}
+
+	return MatchKindOf(main, s) != NoMatch
 }
 
 func InputMapFindOrInsert(main, s string,  m *map[string]bool) bool {
@@ -67,24 +162,128 @@ func InputMapFindOrInsert(main, s string,  m *map[string]bool) bool {
 	return passed
 }
 
-func FindInStops(stops []Stop, s string) (ret []Stop) {
-	namePassed := make(map[string]bool)
-	
-	for _, stop := range stops {
-		nondigit := strings.IndexFunc(s, func(r rune) bool {
-			return !unicode.IsDigit(r)
-		})
-		
-		if nondigit == -1 && strings.HasPrefix(strconv.Itoa(stop.LineNr), s) {
+// The stops matching the query, the name decides the order and the other
+// parts of it only leave some of them out
+func FindInStops(stops []Stop, query Query) (ret []Stop) {
+	name := query.Name()
+	pinned, isPinned := PinnedName(name)
+
+	index := CurrentSearchIndex()
+	var found []Stop
+	switch {
+	case query.IsLineNumber():
+		found = RankStops(stops, query.Text)
+	case len(name) == 0:
+		found = stops
+	case isPinned:
+		for _, stop := range stops {
+			if stop.Name == pinned {
+				found = append(found, stop)
+			}
+		}
+	case index != nil:
+		found = index.Stops(stops, name)
+	default:
+		found = RankStops(stops, name)
+	}
+
+	for _, stop := range found {
+		if query.Keeps(stop) {
 			ret = append(ret, stop)
-		} else if InputMapFindOrInsert(stop.Name, s, &namePassed) {
-				ret = append(ret, stop)
 		}
 	}
+
 	return
 }
 
-func FindConnections(from, to string, stops []Stop) (ret []Connection) {
+// Optional stops that a connection has to pass through or must not touch,
+// matched the same way as the From and To fields
+type Constraints struct {
+	Via, Avoid string
+	Lines LineFilter
+	// Rides only on vehicles marked as low-floor
+	LowFloor bool
+
+	viaPassed, avoidPassed map[string]bool
+}
+
+func NewConstraints(via, avoid string) *Constraints {
+	return &Constraints {
+		Via: via,
+		Avoid: avoid,
+		viaPassed: make(map[string]bool),
+		avoidPassed: make(map[string]bool),
+	}
+}
+
+func (c *Constraints) IsEmpty() bool {
+	return c == nil || (len(c.Via) == 0 && len(c.Avoid) == 0 && c.Lines.IsEmpty() && !c.LowFloor)
+}
+
+func (c *Constraints) Avoids(name string) bool {
+	return c != nil && len(c.Avoid) != 0 && InputMapFindOrInsert(name, c.Avoid, &c.avoidPassed)
+}
+
+func (c *Constraints) IsVia(name string) bool {
+	return c != nil && len(c.Via) != 0 && InputMapFindOrInsert(name, c.Via, &c.viaPassed)
+}
+
+// Index of the stop the ride passes through in place of the via, the first
+// and the last stop don't count. -1 when there's none, 0 when no via is set.
+func (c *Constraints) ViaIndex(path []Stop) int {
+	if c == nil || len(c.Via) == 0 {
+		return 0
+	}
+
+	for i := 1; i < len(path) - 1; i++ {
+		if c.IsVia(path[i].Name) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (c *Constraints) Allows(path []Stop) bool {
+	if c != nil && !c.Lines.Allows(path[0].LineNr) {
+		return false
+	}
+
+	for _, stop := range path {
+		if c.Avoids(stop.Name) {
+			return false
+		}
+	}
+
+	return c.ViaIndex(path) != -1
+}
+
+// What the ride does to satisfy the constraints, for showing next to it
+func (c *Constraints) Describe(path []Stop) string {
+	var parts []string
+	if i := c.ViaIndex(path); i > 0 {
+		parts = append(parts, "via " + path[i].Name)
+	}
+	if c != nil && len(c.Avoid) != 0 {
+		parts = append(parts, "avoids " + c.Avoid)
+	}
+
+	return strings.Join(parts, ", ")
+}
+
+func ConnectionOnPath(path []Stop, constraints *Constraints) Connection {
+	return Connection {
+		Stop: &path[0],
+		Path: path[0].Name + " -> " + path[len(path) - 1].Name,
+		InfoNext: InfoNextBusOnConnection(path, constraints != nil && constraints.LowFloor),
+		Constraints: constraints.Describe(path),
+		Ride: path,
+		LowFloor: constraints != nil && constraints.LowFloor,
+		Minutes: MinsToNextBus(path[0], constraints != nil && constraints.LowFloor),
+	}
+}
+
+func FindConnections(from, to string, stops []Stop, constraints *Constraints) (ret []Connection) {
 	fromPassed := make(map[string]bool)
 	toPassed := make(map[string]bool)
 	
@@ -100,13 +299,9 @@ func FindConnections(from, to string, stops []Stop) (ret []Connection) {
 			if line != stops[j].LineNr || dir != stops[j].Direction {
 				i += j - 1 - i // skip this many stops, because the are on the same route
 				break
-			} else if InputMapFindOrInsert(stops[j].Name, to, &toPassed) {
-				connection := Connection {
-						Stop: &stops[i],
-						Path: stops[i].Name + " -> " + stops[j].Name,
-						InfoNext: InfoNextBusOnConnection(stops[i:j + 1]),
-					}
-				ret = append(ret, connection)
+			} else if InputMapFindOrInsert(stops[j].Name, to, &toPassed) &&
+				constraints.Allows(stops[i:j + 1]) {
+				ret = append(ret, ConnectionOnPath(stops[i:j + 1], constraints))
 			}
 		}
 	}
@@ -114,7 +309,7 @@ func FindConnections(from, to string, stops []Stop) (ret []Connection) {
 	return
 }
 
-func FindConnectionsOnlyFrom(from string, stops []Stop) (ret []Connection) {
+func FindConnectionsOnlyFrom(from string, stops []Stop, constraints *Constraints) (ret []Connection) {
 	fromPassed := make(map[string]bool)
 
 	stopsLength := len(stops)
@@ -126,26 +321,26 @@ func FindConnectionsOnlyFrom(from string, stops []Stop) (ret []Connection) {
 		line := stops[i].LineNr
 		dir := stops[i].Direction
 
+		// The ride ends before the first stop to avoid
 		j := i
 		for (j + 1) < stopsLength &&
 			line == stops[j + 1].LineNr &&
-			dir == stops[j + 1].Direction {
+			dir == stops[j + 1].Direction &&
+			!constraints.Avoids(stops[j + 1].Name) {
 			j++
 		}
 
-		connection := Connection {
-			Stop: &stops[i],
-			Path: stops[i].Name + " -> " + stops[j].Name,
-			InfoNext: InfoNextBusOnConnection(stops[i:j + 1]),
+		if !constraints.Allows(stops[i:j + 1]) {
+			continue
 		}
 
-		ret = append(ret, connection)
+		ret = append(ret, ConnectionOnPath(stops[i:j + 1], constraints))
 	}
 	
 	return 
 }
 
-func FindConnectionsOnlyTo(to string, stops []Stop) (ret []Connection) {
+func FindConnectionsOnlyTo(to string, stops []Stop, constraints *Constraints) (ret []Connection) {
 	toPassed := make(map[string]bool)
 
 	stopsLength := len(stops)
@@ -153,17 +348,17 @@ func FindConnectionsOnlyTo(to string, stops []Stop) (ret []Connection) {
 		line := stops[i].LineNr
 		dir := stops[i].Direction
 
+		// The ride starts after the last stop to avoid
+		start := i
 		for j := i; j < stopsLength; j++ {
 			if line != stops[j].LineNr || dir != stops[j].Direction {
 				i += j - 1 - i // skip this many stops, because the are on the same route
 				break
-			} else if InputMapFindOrInsert(stops[j].Name, to, &toPassed) {
-				connection := Connection {
-					Stop: &stops[i],
-					Path: stops[i].Name + " -> " + stops[j].Name,
-					InfoNext: InfoNextBusOnConnection(stops[i:j + 1]),
-				}
-				ret = append(ret, connection)
+			} else if constraints.Avoids(stops[j].Name) {
+				start = j + 1
+			} else if InputMapFindOrInsert(stops[j].Name, to, &toPassed) &&
+				start <= j && constraints.Allows(stops[start:j + 1]) {
+				ret = append(ret, ConnectionOnPath(stops[start:j + 1], constraints))
 			}
 		}
 	}
@@ -171,41 +366,22 @@ func FindConnectionsOnlyTo(to string, stops []Stop) (ret []Connection) {
 	return
 }
 
+// Soonest first, then the ones beyond the schedule and last the ones that
+// don't drive today
 func SortConnectionsOnTime(connections []Connection) (result []Connection) {
-	valueOnInfo := func(connection Connection) int {
-		const INT32_MAX int = (1 << 31) - 1
-		if strings.HasPrefix(connection.InfoNext, "Depart") {
-			return 0
-		} else if strings.HasPrefix(connection.InfoNext, "In") {
-			mins := strings.TrimFunc(connection.InfoNext, func (r rune) bool {
-				return unicode.IsLetter(r) || unicode.IsSpace(r)
-			})
-			
-			nondigit := strings.IndexFunc(mins, func(r rune) bool {
-				return !unicode.IsDigit(r)
-			})
-
-			if nondigit != -1 {
-				mins = mins[:nondigit]
-			}
-
-			minsInt, err := strconv.Atoi(mins)
-			if err != nil {
-				panic(err)
-			}
-
-			return minsInt
-		} else if strings.HasPrefix(connection.InfoNext, "Beyond") {
-			return INT32_MAX - 1
-		} else if strings.HasPrefix(connection.InfoNext, "Doesn") {
-			return INT32_MAX
-		} else {
-			panic("Unreachable")
+	value := func(connection Connection) int {
+		switch connection.Minutes {
+		case BeyondSchedule:
+			return Unreachable
+		case NotWorkDays:
+			return Unreachable + 1
+		default:
+			return connection.Minutes
 		}
 	}
-	
-	sort.Slice(connections, func(i, j int) bool {
-		return valueOnInfo(connections[i]) < valueOnInfo(connections[j])
+
+	sort.SliceStable(connections, func(i, j int) bool {
+		return value(connections[i]) < value(connections[j])
 	})
 
 	return connections
@@ -236,13 +412,7 @@ func TimesToOneDay(stops []Stop) (result []Stop) {
 					new.HolidayMins = append(stop.Times.HolidayMins[j + 1:], stop.Times.HolidayMins[:j + 1]...)
 				}
 
-				toAppend = Stop {
-					Id: stop.Id,
-					LineNr: stop.LineNr,
-					Direction: stop.Direction,
-					Name: stop.Name,
-					Times: new,
-				}
+				toAppend.Times = new
 				break;
 			}
 		}
@@ -271,18 +441,86 @@ func CurrentHourIndex(current int, stopHours []string) int {
 	return -1
 }
 
-func TodaysMins(now time.Time, mins Times) []string {
+func SetScheduleLocation(loc *time.Location) {
+	scheduleLocationMutex.Lock()
+	defer scheduleLocationMutex.Unlock()
+	scheduleLocation = loc
+}
+
+func ScheduleLocation() *time.Location {
+	scheduleLocationMutex.RLock()
+	defer scheduleLocationMutex.RUnlock()
+	return scheduleLocation
+}
+
+// Current time in the schedule's time zone, as reported by the clock
+func Now() time.Time {
+	return CurrentClock().Now().In(ScheduleLocation())
+}
+
+// Departure at `hour`:`min` on the day of `day`, in `day`'s time zone. On the
+// night of the DST change in the spring the missing hour is normalized forward,
+// the repeated hour in the autumn resolves to one of its two occurrences.
+func DepartureTime(day time.Time, hour, min int) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, hour, min, 0, 0, day.Location())
+}
+
+// Whole minutes from `from` to `to`, measured in real elapsed time and not in
+// the difference of the wall clocks, so it stays correct across a DST change
+func MinutesBetween(from, to time.Time) int {
+	return int(to.Sub(from.Truncate(time.Minute)) / time.Minute)
+}
+
+type DayType int
+
+const (
+	WorkDay DayType = iota
+	Saturday
+	Holiday
+	DayTypeCount
+)
+
+func (day DayType) String() string {
+	switch day {
+	case WorkDay:
+		return "Work Day"
+	case Saturday:
+		return "Saturday"
+	default:
+		return "Holiday"
+	}
+}
+
+func DayTypeOf(now time.Time) DayType {
 	switch nowDay := now.Weekday(); nowDay {
 	case time.Sunday:
-		return mins.HolidayMins
+		return Holiday
 	case time.Saturday:
-		return mins.SaturdayMins
+		return Saturday
 	default:
-		return mins.WorkMins
+		return WorkDay
 	}
 }
 
-func ClosestsBusTimeIndexes(currentHour, currentMin int, workingMins, workingHours []string) (hi, mi int) {
+func (times Times) Mins(day DayType) []string {
+	switch day {
+	case Holiday:
+		return times.HolidayMins
+	case Saturday:
+		return times.SaturdayMins
+	default:
+		return times.WorkMins
+	}
+}
+
+func TodaysMins(now time.Time, mins Times) []string {
+	return mins.Mins(DayTypeOf(now))
+}
+
+// Indexes of the first departure not earlier than the given time, with
+// `lowFloor` only of the ones marked as served by a low-floor vehicle
+func ClosestsBusTimeIndexes(currentHour, currentMin int, workingMins, workingHours []string, lowFloor bool) (hi, mi int) {
 	hi = CurrentHourIndex(currentHour, workingHours)
 	if hi == -1 {
 		return BeyondSchedule, 0
@@ -292,6 +530,11 @@ func ClosestsBusTimeIndexes(currentHour, currentMin int, workingMins, workingHou
 		// Doesn't drive on today's type of day
 		return NotWorkDays, 0
 	}
+
+	// The minutes only matter when the schedule has the current hour
+	if IntOrPanic(workingHours[hi]) != currentHour {
+		currentMin = 0
+	}
 	
 	for ; hi < len(workingHours); hi++ {
 		minsAtHour := strings.Split(workingMins[hi], " ")
@@ -305,7 +548,7 @@ func ClosestsBusTimeIndexes(currentHour, currentMin int, workingMins, workingHou
 				return unicode.IsLetter(r)
 			})
 
-			if len(minTrimed) == 0 {
+			if len(minTrimed) == 0 || (lowFloor && !IsLowFloor(stopMinute)) {
 				continue
 			}
 
@@ -321,11 +564,11 @@ func ClosestsBusTimeIndexes(currentHour, currentMin int, workingMins, workingHou
 	return BeyondSchedule, 0
 }
 
-func MinsToNextBus(stop Stop) (result int) {
-	now := time.Now()
+func MinsToNextBus(stop Stop, lowFloor bool) (result int) {
+	now := Now()
 	nowHour, nowMin, _ := now.Clock()
 	lookupMins := TodaysMins(now, stop.Times)
-	hoffset, moffset := ClosestsBusTimeIndexes(nowHour, nowMin, lookupMins, stop.Times.Hours)
+	hoffset, moffset := ClosestsBusTimeIndexes(nowHour, nowMin, lookupMins, stop.Times.Hours, lowFloor)
 
 	// Error propagation
 	if hoffset <= BeyondSchedule {
@@ -338,14 +581,36 @@ func MinsToNextBus(stop Stop) (result int) {
 				return unicode.IsLetter(r)
 	}))
 	
-	return (reshour - nowHour) * 60 + resmins - nowMin
+	return MinutesBetween(now, DepartureTime(now, reshour, resmins))
 }
 
-func CommuteLengthFromRoute(stops []Stop) (result int) {
-	now := time.Now()
+// The trip leaving `stop` next, nil when there is none or it wasn't reconstructed
+func NextTrip(stop Stop, lowFloor bool) *Trip {
+	now := Now()
+	nowHour, nowMin, _ := now.Clock()
+	lookupMins := TodaysMins(now, stop.Times)
+	hi, mi := ClosestsBusTimeIndexes(nowHour, nowMin, lookupMins, stop.Times.Hours, lowFloor)
+	if hi <= BeyondSchedule {
+		return nil
+	}
+
+	return stop.TripAt(DayTypeOf(now), hi, mi)
+}
+
+func CommuteLengthFromRoute(stops []Stop, lowFloor bool) (result int) {
+	now := Now()
+	if trip := NextTrip(stops[0], lowFloor); trip != nil {
+		length, ok := trip.RideLength(now, stops[0].Id, stops[len(stops) - 1].Id)
+		if ok {
+			return length
+		}
+	}
+
+	// NOTE(radomski): Without a reconstructed trip the best guess is to take,
+	// on each following stop, the first departure not earlier than the previous one
 	nowHour, nowMin, _ := now.Clock()
 	lookupMins := TodaysMins(now, stops[0].Times)
-	hi, mi := ClosestsBusTimeIndexes(nowHour, nowMin, lookupMins, stops[0].Times.Hours)
+	hi, mi := ClosestsBusTimeIndexes(nowHour, nowMin, lookupMins, stops[0].Times.Hours, lowFloor)
 		
 	nowHour = IntOrPanic(stops[0].Times.Hours[hi])
 	tmp := strings.Split(lookupMins[hi], " ")[mi]
@@ -355,7 +620,7 @@ func CommuteLengthFromRoute(stops []Stop) (result int) {
 
 	for _, stop := range stops[1:] {
 		lookupMins := TodaysMins(now, stop.Times)
-		hi, mi = ClosestsBusTimeIndexes(nowHour, nowMin, lookupMins, stop.Times.Hours)
+		hi, mi = ClosestsBusTimeIndexes(nowHour, nowMin, lookupMins, stop.Times.Hours, lowFloor)
 		if hi <= BeyondSchedule {
 			return result
 		}
@@ -366,7 +631,8 @@ func CommuteLengthFromRoute(stops []Stop) (result int) {
 			return unicode.IsLetter(r)
 		}))
 		
-		result += (resultHour - nowHour) * 60 + resultMin - nowMin
+		result += MinutesBetween(DepartureTime(now, nowHour, nowMin),
+			DepartureTime(now, resultHour, resultMin))
 		
 		nowHour = resultHour
 		nowMin = resultMin
@@ -375,8 +641,8 @@ func CommuteLengthFromRoute(stops []Stop) (result int) {
 	return result
 }
 
-func InfoNextBus(stop Stop) (result string) {
-	switch minNext := MinsToNextBus(stop); minNext {
+func InfoNextBus(stop Stop, lowFloor bool) (result string) {
+	switch minNext := MinsToNextBus(stop, lowFloor); minNext {
 	case BeyondSchedule:
 		return "Beyond schedule"
 	case NotWorkDays:
@@ -392,30 +658,106 @@ func InfoNextBus(stop Stop) (result string) {
 
 // NOTE(radomski): Idealy this would return two strings, one being 
 // minutes until the next bus and second one being the commute length
-func InfoNextBusOnConnection(stops []Stop) (result string) {
-	switch minNext := MinsToNextBus(stops[0]); minNext {
+func InfoNextBusOnConnection(stops []Stop, lowFloor bool) (result string) {
+	switch minNext := MinsToNextBus(stops[0], lowFloor); minNext {
 	case BeyondSchedule:
 		return "Beyond schedule"
 	case NotWorkDays:
 		return "Doesn't drive today"
 	default:
-		commuteLength := CommuteLengthFromRoute(stops)
+		commuteLength := CommuteLengthFromRoute(stops, lowFloor)
+		arrival := Now().Add(time.Duration(minNext + commuteLength) * time.Minute).Format("15:04")
 		if minNext != 0 {
-			return fmt.Sprintf("In %d min [%d min ride]", minNext, commuteLength)			
+			return fmt.Sprintf("In %d min [%d min ride, arrives %s]", minNext, commuteLength, arrival)
 		} else {
-			return fmt.Sprintf("Departing right now! [%d min ride]", commuteLength)
+			return fmt.Sprintf("Departing right now! [%d min ride, arrives %s]", commuteLength, arrival)
 		}
 	}
 }
 
 func Run() {
+	nowFlag := flag.String("now", "", "show the schedule as of this moment, \"" +
+		TimeTravelLayout + "\" or \"15:04\" for today")
+	flag.IntVar(&MaxTransfers, "transfers", MaxTransfers, "most vehicle changes in a planned journey")
+	flag.IntVar(&MinTransferTime, "transfer-time", MinTransferTime, "minutes needed to change vehicles")
+	restore := flag.Bool("restore", false, "come back to the page, the queries and the row of the last session")
+
+	weights, err := ReadSearchWeights(CreateConfigPath("search"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	flag.Float64Var(&weights.Threshold, "fuzzy-threshold", weights.Threshold,
+		"least similarity of a stop name to what was typed, from 0 to 1, 0 for the one of the matcher")
+	flag.StringVar(&weights.Matcher, "matcher", weights.Matcher,
+		"how stop names are compared, jaro-winkler, damerau-levenshtein or subsequence")
+	flag.Usage = func() {
+		PrintUsage(flag.CommandLine.Output())
+		fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	SetSearchWeights(weights)
+
+	matcher, err := ParseMatcher(weights.Matcher)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	SetMatcher(matcher)
+
 	database := NewDatabase()
 	database.CreateFromJSON()
 
+	// NOTE(radomski): Parsed only after loading, because the database
+	// decides in which time zone the moment is given
+	if len(*nowFlag) != 0 {
+		at, err := ParseScheduleTime(*nowFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+
+		SetClock(NewOffsetClock(at))
+	}
+
+	if flag.NArg() != 0 {
+		database.WaitComplete()
+		if database.TimeZoneError != nil {
+			fmt.Fprintln(os.Stderr, database.TimeZoneError)
+		}
+		if err := RunCommand(&database, flag.Args()); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	ui := NewUI()
+	ui.History, err = ReadHistory(CreateStatePath("history"))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if *restore {
+		session, err := ReadSession(CreateStatePath("session"))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+		}
+		ui.Restore = &session
+	}
+
 	go ui.UpdateUncompleteTable(&database)
+	go ui.TickEveryMinute(&database)
 	ui.CreatePages(&database)
 	if err := app.SetRoot(ui.Pages, true).EnableMouse(true).Run(); err != nil {
 		panic(err)
 	}
+
+	ui.RememberQueries()
+	if err := WriteHistory(CreateStatePath("history"), ui.History); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+	if err := WriteSession(CreateStatePath("session"), ui.CurrentSession()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
 }
@@ -0,0 +1,292 @@
+package scheduler
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func loadWarsaw(t *testing.T) *time.Location {
+	loc, err := time.LoadLocation("Europe/Warsaw")
+	if err != nil {
+		t.Skipf("No tzdata for Europe/Warsaw: %v", err)
+	}
+
+	return loc
+}
+
+func TestDepartureTimeAcrossDST(t *testing.T) {
+	warsaw := loadWarsaw(t)
+
+	tests := []struct {
+		name string
+		day time.Time
+		hour, min int
+		want string
+	}{
+		{ "spring, before the change", time.Date(2020, 3, 29, 0, 0, 0, 0, warsaw), 1, 50, "2020-03-29T01:50:00+01:00" },
+		{ "spring, the missing hour", time.Date(2020, 3, 29, 0, 0, 0, 0, warsaw), 2, 30, "2020-03-29T03:30:00+02:00" },
+		{ "spring, after the change", time.Date(2020, 3, 29, 0, 0, 0, 0, warsaw), 3, 10, "2020-03-29T03:10:00+02:00" },
+		{ "spring, late in the day", time.Date(2020, 3, 29, 23, 0, 0, 0, warsaw), 23, 59, "2020-03-29T23:59:00+02:00" },
+		{ "autumn, before the change", time.Date(2020, 10, 25, 0, 0, 0, 0, warsaw), 1, 50, "2020-10-25T01:50:00+02:00" },
+		{ "autumn, after the change", time.Date(2020, 10, 25, 0, 0, 0, 0, warsaw), 3, 10, "2020-10-25T03:10:00+01:00" },
+		{ "autumn, day given in UTC", time.Date(2020, 10, 25, 12, 0, 0, 0, warsaw).UTC(), 12, 0, "2020-10-25T12:00:00Z" },
+	}
+
+	for _, test := range tests {
+		got := DepartureTime(test.day, test.hour, test.min).Format(time.RFC3339)
+		if got != test.want {
+			t.Errorf("%s: DepartureTime(%v, %d, %d) = %s, want %s",
+				test.name, test.day, test.hour, test.min, got, test.want)
+		}
+	}
+}
+
+func TestMinutesBetweenAcrossDST(t *testing.T) {
+	warsaw := loadWarsaw(t)
+	at := func(month, day, hour, min, sec int) time.Time {
+		return time.Date(2020, time.Month(month), day, hour, min, sec, 0, warsaw)
+	}
+
+	tests := []struct {
+		name string
+		from, to time.Time
+		want int
+	}{
+		{ "ordinary day", at(3, 28, 1, 50, 0), at(3, 28, 3, 10, 0), 80 },
+		{ "spring, over the missing hour", at(3, 29, 1, 50, 0), at(3, 29, 3, 10, 0), 20 },
+		{ "spring, whole day", at(3, 29, 0, 0, 0), at(3, 30, 0, 0, 0), 23 * 60 },
+		{ "autumn, over the repeated hour", at(10, 25, 1, 50, 0), at(10, 25, 3, 10, 0), 140 },
+		{ "autumn, whole day", at(10, 25, 0, 0, 0), at(10, 26, 0, 0, 0), 25 * 60 },
+		{ "seconds of now are dropped", at(10, 25, 1, 50, 45), at(10, 25, 1, 55, 0), 5 },
+		{ "already gone", at(3, 29, 3, 10, 0), at(3, 29, 1, 50, 0), -20 },
+	}
+
+	for _, test := range tests {
+		if got := MinutesBetween(test.from, test.to); got != test.want {
+			t.Errorf("%s: MinutesBetween(%v, %v) = %d, want %d", test.name, test.from, test.to, got, test.want)
+		}
+	}
+}
+
+// Runs the test at `at` in Kraków, restoring the real clock afterwards
+func setNow(t *testing.T, at time.Time) {
+	location := ScheduleLocation()
+	SetScheduleLocation(at.Location())
+	SetClock(FixedClock { at })
+	t.Cleanup(func() {
+		SetClock(SystemClock{})
+		SetScheduleLocation(location)
+	})
+}
+
+func testStop(id int, hours, work, saturday, holiday []string) Stop {
+	return Stop {
+		Id: id,
+		LineNr: 52,
+		Direction: "Czerwone Maki P+R",
+		Name: "Rondo Mogilskie",
+		Times: Times {
+			Hours: hours,
+			WorkMins: work,
+			SaturdayMins: saturday,
+			HolidayMins: holiday,
+		},
+	}
+}
+
+func TestClosestsBusTimeIndexes(t *testing.T) {
+	hours := []string { "5", "6", "7" }
+	mins := []string { "10 40", "05n 35", "" }
+
+	tests := []struct {
+		name string
+		hour, min int
+		mins []string
+		lowFloor bool
+		wantHi, wantMi int
+	}{
+		{ "before the first hour", 4, 30, mins, false, 0, 0 },
+		{ "first departure", 5, 0, mins, false, 0, 0 },
+		{ "departing this minute", 5, 10, mins, false, 0, 0 },
+		{ "second of the hour", 5, 11, mins, false, 0, 1 },
+		{ "next hour", 5, 41, mins, false, 1, 0 },
+		{ "annotated minute", 6, 5, mins, false, 1, 0 },
+		{ "last departure", 6, 6, mins, false, 1, 1 },
+		{ "empty last hour", 6, 36, mins, false, BeyondSchedule, 0 },
+		{ "after the last hour", 8, 0, mins, false, BeyondSchedule, 0 },
+		{ "low-floor skips others", 5, 0, mins, true, 1, 0 },
+		{ "no low-floor left", 6, 6, mins, true, BeyondSchedule, 0 },
+		{ "doesn't drive that day", 5, 0, nil, false, NotWorkDays, 0 },
+	}
+
+	for _, test := range tests {
+		hi, mi := ClosestsBusTimeIndexes(test.hour, test.min, test.mins, hours, test.lowFloor)
+		if hi != test.wantHi || mi != test.wantMi {
+			t.Errorf("%s: ClosestsBusTimeIndexes(%d, %d) = %d, %d, want %d, %d",
+				test.name, test.hour, test.min, hi, mi, test.wantHi, test.wantMi)
+		}
+	}
+}
+
+func TestMinsToNextBus(t *testing.T) {
+	warsaw := loadWarsaw(t)
+	stop := testStop(1, []string { "5", "6", "7" }, []string { "10 40", "05n 35", "" },
+		[]string { "30", "", "" }, nil)
+
+	tests := []struct {
+		name string
+		at time.Time
+		lowFloor bool
+		want int
+	}{
+		{ "monday morning", time.Date(2020, 6, 1, 5, 0, 0, 0, warsaw), false, 10 },
+		{ "seconds don't count", time.Date(2020, 6, 1, 5, 9, 30, 0, warsaw), false, 1 },
+		{ "departing right now", time.Date(2020, 6, 1, 5, 10, 59, 0, warsaw), false, 0 },
+		{ "across the hour", time.Date(2020, 6, 1, 5, 41, 0, 0, warsaw), false, 24 },
+		{ "low-floor", time.Date(2020, 6, 1, 5, 0, 0, 0, warsaw), true, 65 },
+		{ "after the last one", time.Date(2020, 6, 1, 6, 36, 0, 0, warsaw), false, BeyondSchedule },
+		{ "saturday", time.Date(2020, 5, 30, 5, 0, 0, 0, warsaw), false, 30 },
+		{ "sunday", time.Date(2020, 5, 31, 5, 0, 0, 0, warsaw), false, NotWorkDays },
+		{ "clock in another zone", time.Date(2020, 6, 1, 3, 0, 0, 0, time.UTC), false, 10 },
+	}
+
+	for _, test := range tests {
+		setNow(t, test.at)
+		// NOTE(radomski): The schedule stays in Kraków whatever zone the clock reports
+		SetScheduleLocation(warsaw)
+		if got := MinsToNextBus(stop, test.lowFloor); got != test.want {
+			t.Errorf("%s: MinsToNextBus at %v = %d, want %d", test.name, test.at, got, test.want)
+		}
+	}
+}
+
+func TestCommuteLengthFromRoute(t *testing.T) {
+	warsaw := loadWarsaw(t)
+	setNow(t, time.Date(2020, 6, 1, 5, 0, 0, 0, warsaw))
+
+	first := testStop(1, []string { "5", "6" }, []string { "10 50n", "" }, nil, nil)
+	second := testStop(2, []string { "5", "6" }, []string { "15 25 55n", "" }, nil, nil)
+	third := testStop(3, []string { "5", "6" }, []string { "", "02n" }, nil, nil)
+	ended := testStop(4, []string { "5" }, []string { "20" }, nil, nil)
+
+	tests := []struct {
+		name string
+		stops []Stop
+		lowFloor bool
+		want int
+	}{
+		{ "one ride", []Stop { first, second }, false, 5 },
+		{ "waits for the next hour", []Stop { first, second, third }, false, 52 },
+		{ "low-floor", []Stop { first, second, third }, true, 12 },
+		{ "stops where the schedule ends", []Stop { first, second, ended }, true, 5 },
+	}
+
+	for _, test := range tests {
+		if got := CommuteLengthFromRoute(test.stops, test.lowFloor); got != test.want {
+			t.Errorf("%s: CommuteLengthFromRoute = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSortConnectionsOnTime(t *testing.T) {
+	warsaw := loadWarsaw(t)
+	setNow(t, time.Date(2020, 6, 1, 5, 0, 0, 0, warsaw))
+
+	stops := []Stop {
+		testStop(1, []string { "5" }, []string { "" }, nil, nil),
+		testStop(2, []string { "5" }, []string { "30" }, nil, nil),
+		testStop(3, []string { "4" }, []string { "30" }, nil, nil),
+		testStop(4, []string { "5" }, []string { "0" }, nil, nil),
+		testStop(5, []string { "5" }, nil, nil, nil),
+		testStop(6, []string { "6" }, []string { "5" }, nil, nil),
+		testStop(7, []string { "5" }, []string { "3" }, nil, nil),
+	}
+
+	var connections []Connection
+	for _, stop := range stops {
+		connections = append(connections, ConnectionFromStop(stop, false))
+	}
+
+	want := []int { 4, 7, 2, 6 }
+	sorted := SortConnectionsOnTime(connections)
+	for i, id := range want {
+		if sorted[i].Stop.Id != id {
+			t.Fatalf("SortConnectionsOnTime put %d (%s) at %d, want %d", sorted[i].Stop.Id, sorted[i].InfoNext, i, id)
+		}
+	}
+
+	// The ones without a departure go last, beyond the schedule before not driving
+	for _, connection := range sorted[len(want):len(want) + 2] {
+		if connection.InfoNext != "Beyond schedule" {
+			t.Errorf("Expected \"Beyond schedule\" after the departures, got \"%s\"", connection.InfoNext)
+		}
+	}
+	if last := sorted[len(sorted) - 1]; last.Stop.Id != 5 {
+		t.Errorf("Expected the stop not driving today last, got %d (%s)", last.Stop.Id, last.InfoNext)
+	}
+}
+
+func TestSortConnectionsOnMinutes(t *testing.T) {
+	// Only the minutes count, whatever the text says
+	minutes := []int { NotWorkDays, 12, BeyondSchedule, 0, 3 }
+	var connections []Connection
+	for i, m := range minutes {
+		stop := Stop { Id: i }
+		connections = append(connections, Connection { Stop: &stop, InfoNext: "Departs soon", Minutes: m })
+	}
+
+	want := []int { 0, 3, 12, BeyondSchedule, NotWorkDays }
+	for i, connection := range SortConnectionsOnTime(connections) {
+		if connection.Minutes != want[i] {
+			t.Errorf("Expected %d minutes at %d, got %d", want[i], i, connection.Minutes)
+		}
+	}
+}
+
+func TestFindInStopsPinned(t *testing.T) {
+	stops := []Stop {
+		testStop(0, nil, nil, nil, nil),
+		testStop(1, nil, nil, nil, nil),
+		testStop(2, nil, nil, nil, nil),
+	}
+	stops[0].Name = "Rondo"
+	stops[1].Name = "Rondo Mogilskie"
+	stops[2].Name, stops[2].LineNr = "Rondo", 4
+
+	index := CurrentSearchIndex()
+	defer SetSearchIndex(index)
+
+	tests := []struct {
+		text string
+		ids []int
+	}{
+		{ `stop:"=Rondo"`, []int { 0, 2 } },
+		{ `stop:"=Rondo" line:4`, []int { 2 } },
+		{ `stop:"=Rondo Mogilskie"`, []int { 1 } },
+		{ `stop:"=Mogilskie"`, nil },
+	}
+
+	for _, indexed := range []bool { false, true } {
+		if indexed {
+			SetSearchIndex(NewSearchIndex(stops, nil))
+		} else {
+			SetSearchIndex(nil)
+		}
+
+		for _, test := range tests {
+			query, err := ParseQuery(test.text)
+			if err != nil {
+				t.Fatalf("ParseQuery(%q) failed: %v", test.text, err)
+			}
+
+			var ids []int
+			for _, stop := range FindInStops(stops, query) {
+				ids = append(ids, stop.Id)
+			}
+			if !reflect.DeepEqual(ids, test.ids) {
+				t.Errorf("FindInStops(%q) with the index %v = %v, want %v", test.text, indexed, ids, test.ids)
+			}
+		}
+	}
+}
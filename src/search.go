@@ -0,0 +1,361 @@
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+type MatchKind int
+
+const (
+	NoMatch MatchKind = iota
+	FuzzyMatch
+	WordPrefixMatch
+	PrefixMatch
+	ExactMatch
+)
+
+// How much each kind of match is worth, the fuzzy similarity is added on
+// top of it, scaled by `Fuzzy`, to order the matches of the same kind
+type SearchWeights struct {
+	Exact, Prefix, WordPrefix, Fuzzy float64
+	// Least similarity for a name that only matches fuzzily, 0 for the
+	// threshold of the matcher
+	Threshold float64
+	// Name of the `Matcher` comparing the names
+	Matcher string
+}
+
+var DefaultSearchWeights = SearchWeights {
+	Exact: 4,
+	Prefix: 3,
+	WordPrefix: 2,
+	Fuzzy: 1,
+	Threshold: 0,
+	Matcher: "jaro-winkler",
+}
+
+var searchWeights = DefaultSearchWeights
+
+func SetSearchWeights(weights SearchWeights) {
+	searchWeights = weights
+}
+
+// Reads "name;value" lines, the names are those of the `SearchWeights` fields
+// and the value of "matcher" is one of `Matchers`
+func ReadSearchWeights(path string) (weights SearchWeights, e error) {
+	weights = DefaultSearchWeights
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return weights, nil
+	} else if err != nil {
+		return weights, err
+	}
+
+	fields := map[string]*float64 {
+		"exact": &weights.Exact,
+		"prefix": &weights.Prefix,
+		"word-prefix": &weights.WordPrefix,
+		"fuzzy": &weights.Fuzzy,
+		"threshold": &weights.Threshold,
+	}
+
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		if len(parts) != 2 {
+			e = fmt.Errorf("%s:%d: expected \"name;value\"", path, i + 1)
+			continue
+		}
+
+		if strings.TrimSpace(parts[0]) == "matcher" {
+			if _, err := ParseMatcher(parts[1]); err != nil {
+				e = fmt.Errorf("%s:%d: %v", path, i + 1, err)
+				continue
+			}
+
+			weights.Matcher = strings.TrimSpace(parts[1])
+			continue
+		}
+
+		field, present := fields[strings.TrimSpace(parts[0])]
+		if !present {
+			e = fmt.Errorf("%s:%d: unknown weight \"%s\"", path, i + 1, parts[0])
+			continue
+		}
+
+		value, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil {
+			e = fmt.Errorf("%s:%d: \"%s\" is not a number", path, i + 1, parts[1])
+			continue
+		}
+
+		*field = value
+	}
+
+	return
+}
+
+func isWordSeparator(r rune) bool {
+	return unicode.IsSpace(r) || unicode.IsPunct(r)
+}
+
+func MatchKindOf(name, query string) MatchKind {
+	name, query = Normalize(name), Normalize(query)
+	if len(query) == 0 {
+		return NoMatch
+	}
+
+	switch {
+	case name == query:
+		return ExactMatch
+	case strings.HasPrefix(name, query):
+		return PrefixMatch
+	}
+
+	words := strings.FieldsFunc(name, isWordSeparator)
+	if kind, _ := matchTokens(words, strings.FieldsFunc(query, isWordSeparator)); kind != NoMatch {
+		return kind
+	}
+
+	if searchMatcher.Similarity(name, query) >= similarityThreshold() {
+		return FuzzyMatch
+	}
+
+	return NoMatch
+}
+
+// Every word of the query has to match a different word of the name, in
+// any order, by prefix or at least fuzzily. The worst of them decides.
+// Also tells which word each of the tokens matched.
+func matchTokens(words, tokens []string) (MatchKind, []int) {
+	if len(tokens) == 0 || len(tokens) > len(words) {
+		return NoMatch, nil
+	}
+
+	// How each token matches each word, a prefix is worth more than any similarity
+	kinds := make([][]MatchKind, len(tokens))
+	scores := make([][]float64, len(tokens))
+	for t, token := range tokens {
+		kinds[t] = make([]MatchKind, len(words))
+		scores[t] = make([]float64, len(words))
+		for i, word := range words {
+			if strings.HasPrefix(word, token) {
+				kinds[t][i], scores[t][i] = WordPrefixMatch, 2.0
+			} else if similarity := searchMatcher.Similarity(word, token); similarity >= similarityThreshold() {
+				kinds[t][i], scores[t][i] = FuzzyMatch, similarity
+			}
+		}
+	}
+
+	// NOTE(radomski): Names have a handful of words, so trying every way of
+	// pairing them with the tokens is cheap. Taking the first word that fits
+	// could leave a later token without the only word it matches.
+	bestKind, bestScore := NoMatch, 0.0
+	var best []int
+	used := make([]bool, len(words))
+	matched := make([]int, len(tokens))
+	var pair func(t int, kind MatchKind, score float64)
+	pair = func(t int, kind MatchKind, score float64) {
+		if kind < bestKind {
+			return
+		}
+
+		if t == len(tokens) {
+			if kind > bestKind || score > bestScore {
+				bestKind, bestScore = kind, score
+				best = append([]int(nil), matched...)
+			}
+			return
+		}
+
+		for i := range words {
+			if used[i] || kinds[t][i] == NoMatch {
+				continue
+			}
+
+			used[i], matched[t] = true, i
+			if kinds[t][i] < kind {
+				pair(t + 1, kinds[t][i], score + scores[t][i])
+			} else {
+				pair(t + 1, kind, score + scores[t][i])
+			}
+			used[i] = false
+		}
+	}
+	pair(0, WordPrefixMatch, 0.0)
+
+	return bestKind, best
+}
+
+// Where the words of the letters start and end
+func wordSpans(runes []rune) (spans [][2]int) {
+	start := -1
+	for i := 0; i <= len(runes); i++ {
+		separator := i == len(runes) || isWordSeparator(runes[i])
+		if separator && start != -1 {
+			spans = append(spans, [2]int { start, i })
+			start = -1
+		} else if !separator && start == -1 {
+			start = i
+		}
+	}
+
+	return
+}
+
+// For every letter of the name whether it made the name match the query
+func MatchedLetters(name, query string) []bool {
+	marked := make([]bool, len([]rune(name)))
+	if pinned, ok := PinnedName(query); ok {
+		for i := range marked {
+			marked[i] = pinned == name
+		}
+		return marked
+	}
+
+	runes, origin := normalizeRunes(name)
+	mark := func(offset int, positions ...int) {
+		for _, p := range positions {
+			marked[origin[offset + p]] = true
+		}
+	}
+	prefix := func(length int) (positions []int) {
+		for i := 0; i < length; i++ {
+			positions = append(positions, i)
+		}
+		return
+	}
+
+	query = Normalize(query)
+	switch MatchKindOf(name, query) {
+	case NoMatch:
+	case ExactMatch, PrefixMatch:
+		mark(0, prefix(Min(len([]rune(query)), len(runes)))...)
+	default:
+		spans := wordSpans(runes)
+		words := make([]string, len(spans))
+		for i, span := range spans {
+			words[i] = string(runes[span[0]:span[1]])
+		}
+
+		tokens := strings.FieldsFunc(query, isWordSeparator)
+		kind, matched := matchTokens(words, tokens)
+		if kind == NoMatch {
+			mark(0, searchMatcher.Positions(string(runes), query)...)
+			break
+		}
+
+		for t, w := range matched {
+			if strings.HasPrefix(words[w], tokens[t]) {
+				mark(spans[w][0], prefix(len([]rune(tokens[t])))...)
+			} else {
+				mark(spans[w][0], searchMatcher.Positions(words[w], tokens[t])...)
+			}
+		}
+	}
+
+	return marked
+}
+
+// How well the stop name fits what was typed, higher is better, false when
+// it doesn't match at all
+func ScoreName(name, query string) (float64, bool) {
+	kind := MatchKindOf(name, query)
+	if kind == NoMatch {
+		return 0, false
+	}
+
+	return KindScore(kind, name, query), true
+}
+
+func KindScore(kind MatchKind, name, query string) float64 {
+	score := searchWeights.Fuzzy * searchMatcher.Similarity(Normalize(name), Normalize(query))
+	switch kind {
+	case ExactMatch:
+		score += searchWeights.Exact
+	case PrefixMatch:
+		score += searchWeights.Prefix
+	case WordPrefixMatch:
+		score += searchWeights.WordPrefix
+	}
+
+	return score
+}
+
+// Score of a line number, exact numbers before the ones it's only a prefix of
+func scoreLine(lineNr int, query string) (float64, bool) {
+	number := strconv.Itoa(lineNr)
+	if number == query {
+		return searchWeights.Exact, true
+	} else if strings.HasPrefix(number, query) {
+		return searchWeights.Prefix, true
+	}
+
+	return 0, false
+}
+
+// The stops matching the query, best first. Stops with the same name stay
+// together, in the order of the database.
+func RankStops(stops []Stop, query string) []Stop {
+	type ranked struct {
+		stop Stop
+		score float64
+	}
+
+	numeric := strings.IndexFunc(query, func(r rune) bool {
+		return !unicode.IsDigit(r)
+	}) == -1
+
+	type nameScore struct {
+		score float64
+		ok bool
+	}
+	names := make(map[string]nameScore)
+
+	var result []ranked
+	for _, stop := range stops {
+		var score float64
+		var ok bool
+
+		if numeric {
+			score, ok = scoreLine(stop.LineNr, query)
+		} else {
+			cached, present := names[stop.Name]
+			if !present {
+				cached.score, cached.ok = ScoreName(stop.Name, query)
+				names[stop.Name] = cached
+			}
+			score, ok = cached.score, cached.ok
+		}
+
+		if ok {
+			result = append(result, ranked { stop, score })
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].score != result[j].score {
+			return result[i].score > result[j].score
+		}
+		// NOTE(radomski): Stops of a line keep the order of its route
+		return !numeric && result[i].stop.Name < result[j].stop.Name
+	})
+
+	ret := make([]Stop, len(result))
+	for i, r := range result {
+		ret[i] = r.stop
+	}
+
+	return ret
+}
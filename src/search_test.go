@@ -0,0 +1,37 @@
+package scheduler
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchTokens(t *testing.T) {
+	tests := []struct {
+		name, query string
+		kind MatchKind
+		matched []int
+	}{
+		{ "Rondo Mogilskie", "mog ron", WordPrefixMatch, []int { 1, 0 } },
+		{ "Rondo Mogilskie", "rondo mogilskei", FuzzyMatch, []int { 0, 1 } },
+		// The first word fits "k", but "kopiec" has nowhere else to go
+		{ "Kopiec Kościuszki", "k kopiec", WordPrefixMatch, []int { 1, 0 } },
+		{ "Kopiec Kościuszki", "kosciuszko kop", FuzzyMatch, []int { 1, 0 } },
+		// A prefix is preferred over a fuzzy match of the same token
+		{ "Teatr Bagatela", "teatr bagatela", WordPrefixMatch, []int { 0, 1 } },
+		{ "Nowy Bieżanów P+R", "biez nowy", WordPrefixMatch, []int { 1, 0 } },
+		{ "Rondo Mogilskie", "rondo rondo", NoMatch, nil },
+		{ "Rondo Mogilskie", "rondo mogilskie grzegorzeckie", NoMatch, nil },
+		{ "Rondo Mogilskie", "czyzyny", NoMatch, nil },
+	}
+
+	for _, test := range tests {
+		words := strings.FieldsFunc(Normalize(test.name), isWordSeparator)
+		tokens := strings.FieldsFunc(Normalize(test.query), isWordSeparator)
+		kind, matched := matchTokens(words, tokens)
+		if kind != test.kind || !reflect.DeepEqual(matched, test.matched) {
+			t.Errorf("matchTokens(%q, %q) = %d, %v, want %d, %v",
+				test.name, test.query, kind, matched, test.kind, test.matched)
+		}
+	}
+}
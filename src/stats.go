@@ -0,0 +1,136 @@
+package scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type HeadwayStats struct {
+	Departures int
+	First, Last Departure
+	PerHour [24]int
+
+	// Minutes between consecutive departures, both are 0 with less than two of them
+	AverageHeadway, MaxHeadway int
+}
+
+// Hour with the most departures, the earliest one when there are ties
+func (stats HeadwayStats) BusiestHour() (hour, departures int) {
+	for h := ServiceDayStartHour; h < ServiceDayStartHour + 24; h++ {
+		if count := stats.PerHour[h % 24]; count > departures {
+			hour, departures = h % 24, count
+		}
+	}
+
+	return
+}
+
+// Hours with a departure on any of the days, in the order of the service day
+func ServedHours(stats []HeadwayStats) (hours []int) {
+	for h := ServiceDayStartHour; h < ServiceDayStartHour + 24; h++ {
+		for _, day := range stats {
+			if day.PerHour[h % 24] != 0 {
+				hours = append(hours, h % 24)
+				break
+			}
+		}
+	}
+
+	return
+}
+
+func StatsFromDepartures(departures []Departure) (stats HeadwayStats) {
+	stats.Departures = len(departures)
+	if len(departures) == 0 {
+		return
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].ServiceMinute() < departures[j].ServiceMinute()
+	})
+
+	stats.First = departures[0]
+	stats.Last = departures[len(departures) - 1]
+
+	for i, dep := range departures {
+		stats.PerHour[dep.Hour]++
+		if i == 0 {
+			continue
+		}
+
+		headway := dep.ServiceMinute() - departures[i - 1].ServiceMinute()
+		stats.MaxHeadway = Max(stats.MaxHeadway, headway)
+	}
+
+	if len(departures) > 1 {
+		span := stats.Last.ServiceMinute() - stats.First.ServiceMinute()
+		stats.AverageHeadway = span / (len(departures) - 1)
+	}
+
+	return
+}
+
+func StatsForStop(stop Stop, day DayType) HeadwayStats {
+	return StatsFromDepartures(ParseDepartures(stop.Times.Hours, stop.Times.Mins(day)))
+}
+
+type RouteStats struct {
+	LineNr int
+	Direction string
+	// The route's first stop, where its trips are counted
+	FirstStop Stop
+	Stats [DayTypeCount]HeadwayStats
+}
+
+// Statistics of every direction of the line, taken at the first stop of each route
+func StatsForLine(stops []Stop, lineNr int) (result []RouteStats) {
+	for i, stop := range stops {
+		if stop.LineNr != lineNr {
+			continue
+		}
+
+		if i != 0 && stops[i - 1].LineNr == stop.LineNr && stops[i - 1].Direction == stop.Direction {
+			continue
+		}
+
+		route := RouteStats {
+			LineNr: lineNr,
+			Direction: stop.Direction,
+			FirstStop: stop,
+		}
+		for day := WorkDay; day < DayTypeCount; day++ {
+			route.Stats[day] = StatsForStop(stop, day)
+		}
+
+		result = append(result, route)
+	}
+
+	return
+}
+
+const StatsHeaders = "Day;First;Last;Departures;Avg headway;Max headway;Busiest hour"
+
+// Cells of a row under `StatsHeaders`, without the day type
+func (stats HeadwayStats) Columns() []string {
+	if stats.Departures == 0 {
+		return []string { "-", "-", "0", "-", "-", "-" }
+	}
+
+	headway := func(mins int) string {
+		if stats.Departures < 2 {
+			return "-"
+		}
+		return fmt.Sprintf("%d min", mins)
+	}
+
+	hour, departures := stats.BusiestHour()
+	return []string {
+		fmt.Sprintf("%02d:%02d", stats.First.Hour, stats.First.Minute),
+		fmt.Sprintf("%02d:%02d", stats.Last.Hour, stats.Last.Minute),
+		strconv.Itoa(stats.Departures),
+		headway(stats.AverageHeadway),
+		headway(stats.MaxHeadway),
+		fmt.Sprintf("%02d:00 (%d)", hour, departures),
+	}
+}
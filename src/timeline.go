@@ -0,0 +1,142 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// One stop of a journey as it is passed, in the order of travel
+type TimelineEntry struct {
+	Time Departure
+	Stop string
+	// Line and direction of the vehicle, empty while walking
+	Vehicle string
+	Note string
+}
+
+const TimelineHeaders = "Time;Stop;Vehicle;Note"
+
+func (entry TimelineEntry) Columns() []string {
+	return []string {
+		fmt.Sprintf("%02d:%02d", entry.Time.Hour, entry.Time.Minute),
+		entry.Stop,
+		entry.Vehicle,
+		entry.Note,
+	}
+}
+
+// Every stop the journey passes, with the times of the trips it rides. The
+// stop ids of the trips' calls are indexes into `stops`.
+func (journey Journey) Timeline(stops []Stop) (result []TimelineEntry) {
+	for i, leg := range journey.Legs {
+		if leg.IsWalk() {
+			result = append(result, TimelineEntry {
+				Time: leg.Departure,
+				Stop: leg.From.Name,
+				Note: fmt.Sprintf("walk %d min to %s", leg.Minutes(), leg.To.Name),
+			})
+			continue
+		}
+
+		vehicle := fmt.Sprintf("%d towards %s", leg.Trip.LineNr, leg.Trip.Direction)
+		board := "board"
+		if i != 0 && !journey.Legs[i - 1].IsWalk() {
+			board = "transfer"
+		}
+		if leg.Wait != 0 {
+			board += fmt.Sprintf(", wait %d min", leg.Wait)
+		}
+
+		riding := false
+		for _, call := range leg.Trip.Calls {
+			if call.StopId == leg.Board.StopId {
+				riding = true
+			} else if !riding {
+				continue
+			}
+
+			entry := TimelineEntry {
+				Time: call.Departure,
+				Stop: stops[call.StopId].Name,
+				Vehicle: vehicle,
+			}
+
+			switch call.StopId {
+			case leg.Board.StopId:
+				entry.Note = board
+			case leg.Alight.StopId:
+				entry.Note = "alight"
+			}
+
+			result = append(result, entry)
+			if call.StopId == leg.Alight.StopId {
+				break
+			}
+		}
+	}
+
+	if len(journey.Legs) != 0 && journey.Last().IsWalk() {
+		last := journey.Last()
+		result = append(result, TimelineEntry {
+			Time: last.Arrival,
+			Stop: last.To.Name,
+			Note: "arrive",
+		})
+	}
+
+	return
+}
+
+// The direct ride as a journey on the next trip leaving its first stop
+func JourneyOnRide(ride []Stop, lowFloor bool) (Journey, bool) {
+	first, last := ride[0], ride[len(ride) - 1]
+	trip := NextTrip(first, lowFloor)
+	if trip == nil {
+		return Journey{}, false
+	}
+
+	board, ok := trip.CallAt(first.Id)
+	alight, ok2 := trip.CallAt(last.Id)
+	if !ok || !ok2 || alight.Departure.ServiceMinute() < board.Departure.ServiceMinute() {
+		return Journey{}, false
+	}
+
+	now := Now()
+	return Journey {
+		Legs: []Leg {
+			{
+				Trip: trip,
+				From: first,
+				To: last,
+				Board: board,
+				Alight: alight,
+				Departure: board.Departure,
+				Arrival: alight.Departure,
+				Wait: MinutesBetween(now, board.Time(now)),
+			},
+		},
+	}, true
+}
+
+func (journey Journey) Summary() string {
+	now := Now()
+	first, last := journey.First(), journey.Last()
+
+	transfers := "direct"
+	if journey.Transfers() == 1 {
+		transfers = "1 transfer"
+	} else if journey.Transfers() > 1 {
+		transfers = strconv.Itoa(journey.Transfers()) + " transfers"
+	}
+
+	summary := fmt.Sprintf("%s -> %s, %02d:%02d -> %02d:%02d, %d min, %s",
+		first.From.Name, last.To.Name,
+		first.Departure.Hour, first.Departure.Minute,
+		last.Arrival.Hour, last.Arrival.Minute,
+		journey.Duration(now), transfers)
+	if walking := journey.WalkingMinutes(); walking != 0 {
+		summary += fmt.Sprintf(", %d min walking", walking)
+	}
+
+	return summary
+}
@@ -0,0 +1,155 @@
+package scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var (
+	// Stops of different names further apart than that get no footpath
+	MaxWalkDistance = 400.0
+	// Meters per minute, measured along the straight line between the stops
+	WalkingSpeed = 60.0
+)
+
+// Time to walk between two stops, `From` and `To` being the same name sets
+// the time to change poles at that stop
+type TransferRule struct {
+	From, To string
+	Minutes int
+}
+
+type Footpath struct {
+	To int
+	Minutes int
+}
+
+// Reads the user's transfers file, one "Stop A;Stop B;minutes" per line.
+// Empty lines and lines starting with # are skipped, a missing file is fine.
+func ReadTransfers(path string) (rules []TransferRule, e error) {
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for i, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		parts := strings.Split(line, ";")
+		if len(parts) != 3 {
+			e = fmt.Errorf("%s:%d: expected \"Stop A;Stop B;minutes\"", path, i + 1)
+			continue
+		}
+
+		minutes, err := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err != nil || minutes < 0 {
+			e = fmt.Errorf("%s:%d: \"%s\" is not a number of minutes", path, i + 1, parts[2])
+			continue
+		}
+
+		rules = append(rules, TransferRule {
+			From: strings.TrimSpace(parts[0]),
+			To: strings.TrimSpace(parts[1]),
+			Minutes: minutes,
+		})
+	}
+
+	return
+}
+
+// Great-circle distance in meters
+func Distance(lat1, lon1, lat2, lon2 float64) float64 {
+	const earthRadius = 6371000.0
+	toRad := func(deg float64) float64 {
+		return deg * math.Pi / 180
+	}
+
+	dlat := toRad(lat2 - lat1)
+	dlon := toRad(lon2 - lon1)
+	a := math.Sin(dlat / 2) * math.Sin(dlat / 2) +
+		math.Cos(toRad(lat1)) * math.Cos(toRad(lat2)) * math.Sin(dlon / 2) * math.Sin(dlon / 2)
+
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
+}
+
+// Transfer time at every place and footpaths between places, first the ones
+// coming from coordinates and then the user's rules on top of them
+func (tt *Timetable) BuildTransfers(rules []TransferRule) {
+	tt.TransferTime = make([]int, len(tt.Places))
+	tt.Footpaths = make([][]Footpath, len(tt.Places))
+	for place := range tt.TransferTime {
+		tt.TransferTime[place] = MinTransferTime
+	}
+
+	// Position of a place is the average of its stops that have one
+	lat := make([]float64, len(tt.Places))
+	lon := make([]float64, len(tt.Places))
+	count := make([]int, len(tt.Places))
+	for i, stop := range tt.Stops {
+		if stop.Lat == 0 && stop.Lon == 0 {
+			continue
+		}
+
+		place := tt.StopPlace[i]
+		lat[place] += stop.Lat
+		lon[place] += stop.Lon
+		count[place]++
+	}
+
+	for a := range tt.Places {
+		if count[a] == 0 {
+			continue
+		}
+
+		for b := a + 1; b < len(tt.Places); b++ {
+			if count[b] == 0 {
+				continue
+			}
+
+			meters := Distance(lat[a] / float64(count[a]), lon[a] / float64(count[a]),
+				lat[b] / float64(count[b]), lon[b] / float64(count[b]))
+			if meters > MaxWalkDistance {
+				continue
+			}
+
+			minutes := Max(MinTransferTime, int(math.Ceil(meters / WalkingSpeed)))
+			tt.SetFootpath(a, b, minutes)
+			tt.SetFootpath(b, a, minutes)
+		}
+	}
+
+	for _, rule := range rules {
+		from, fromPresent := tt.PlaceOf[rule.From]
+		to, toPresent := tt.PlaceOf[rule.To]
+		if !fromPresent || !toPresent {
+			continue
+		}
+
+		if from == to {
+			tt.TransferTime[from] = rule.Minutes
+		} else {
+			tt.SetFootpath(from, to, rule.Minutes)
+			tt.SetFootpath(to, from, rule.Minutes)
+		}
+	}
+}
+
+func (tt *Timetable) SetFootpath(from, to, minutes int) {
+	for i, path := range tt.Footpaths[from] {
+		if path.To == to {
+			tt.Footpaths[from][i].Minutes = minutes
+			return
+		}
+	}
+
+	tt.Footpaths[from] = append(tt.Footpaths[from], Footpath { To: to, Minutes: minutes })
+}
@@ -0,0 +1,111 @@
+package scheduler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadTransfers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "transfers")
+	if rules, err := ReadTransfers(path); rules != nil || err != nil {
+		t.Fatalf("Expected nothing from a missing file, got %v, %v", rules, err)
+	}
+
+	content := strings.Join([]string {
+		"# Changing poles takes longer here",
+		"Rondo Mogilskie;Rondo Mogilskie;3",
+		" Teatr Bagatela ; Stary Kleparz ; 5 ",
+		"",
+		"Wawel;Salwator",
+		"Wawel;Salwator;-2",
+		"Wawel;Salwator;soon",
+	}, "\n")
+	if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	rules, err := ReadTransfers(path)
+	expected := []TransferRule {
+		{ "Rondo Mogilskie", "Rondo Mogilskie", 3 },
+		{ "Teatr Bagatela", "Stary Kleparz", 5 },
+	}
+	if !reflect.DeepEqual(rules, expected) {
+		t.Errorf("Expected %v, got %v", expected, rules)
+	}
+	if err == nil {
+		t.Error("Expected an error for the bad lines")
+	}
+}
+
+func footpathMinutes(tt *Timetable, from, to string) (int, bool) {
+	for _, path := range tt.Footpaths[tt.PlaceOf[from]] {
+		if path.To == tt.PlaceOf[to] {
+			return path.Minutes, true
+		}
+	}
+
+	return 0, false
+}
+
+func TestBuildTransfers(t *testing.T) {
+	transferTime := MinTransferTime
+	MinTransferTime = 1
+	defer func() {
+		MinTransferTime = transferTime
+	}()
+
+	// A degree of latitude is 111 195 m, Near is 230 m from Here and Far 410 m
+	stop := func(id int, name string, lat float64) Stop {
+		return Stop { Id: id, LineNr: id, Direction: "Direction", Name: name, Lat: lat, Lon: 19.94 }
+	}
+	stops := []Stop {
+		stop(0, "Here", 50.06), stop(1, "Here", 50.06),
+		stop(2, "Near", 50.06 + 230 / 111195.0),
+		stop(3, "Far", 50.06 + 410 / 111195.0),
+		{ Id: 4, LineNr: 4, Direction: "Direction", Name: "Nowhere" },
+	}
+
+	tt := NewTimetable(stops, nil, nil, WorkDay)
+	if minutes := tt.TransferTime[tt.PlaceOf["Here"]]; minutes != MinTransferTime {
+		t.Errorf("Expected %d minutes between the stops named Here, got %d", MinTransferTime, minutes)
+	}
+	if minutes, ok := footpathMinutes(tt, "Here", "Near"); !ok || minutes != 4 {
+		t.Errorf("Expected 4 minutes from Here to Near, got %d, %v", minutes, ok)
+	}
+	if minutes, ok := footpathMinutes(tt, "Near", "Here"); !ok || minutes != 4 {
+		t.Errorf("Expected 4 minutes back from Near, got %d, %v", minutes, ok)
+	}
+	if _, ok := footpathMinutes(tt, "Here", "Far"); ok {
+		t.Error("Expected no footpath to Far, it's further than 400 m")
+	}
+	if len(tt.Footpaths[tt.PlaceOf["Nowhere"]]) != 0 {
+		t.Error("Expected no footpath from a stop without coordinates")
+	}
+
+	rules := []TransferRule {
+		{ "Here", "Here", 3 },
+		{ "Here", "Near", 7 },
+		{ "Near", "Far", 6 },
+		{ "Here", "Unknown", 2 },
+	}
+	tt = NewTimetable(stops, nil, rules, WorkDay)
+	if minutes := tt.TransferTime[tt.PlaceOf["Here"]]; minutes != 3 {
+		t.Errorf("Expected the file's 3 minutes between the stops named Here, got %d", minutes)
+	}
+	if minutes, _ := footpathMinutes(tt, "Here", "Near"); minutes != 7 || len(tt.Footpaths[tt.PlaceOf["Here"]]) != 1 {
+		t.Errorf("Expected the file's 7 minutes in place of the measured footpath, got %d", minutes)
+	}
+	if minutes, ok := footpathMinutes(tt, "Far", "Near"); !ok || minutes != 6 {
+		t.Errorf("Expected the file's footpath from Far to Near, got %d, %v", minutes, ok)
+	}
+}
@@ -0,0 +1,423 @@
+package scheduler
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// Departures after midnight are still a part of the previous day's service,
+// ordering them after the evening ones keeps the night trips together
+const ServiceDayStartHour = 3
+
+// A single departure parsed out of `Times`, the indexes point back into the
+// hours and into the minutes of that hour split on spaces
+type Departure struct {
+	HourIndex, MinIndex int
+	Hour, Minute int
+	Annotation string
+	// Of the service day after the one it's planned in, see `NewNightTimetable`
+	Following bool
+}
+
+// Annotation letters that ZTP puts next to departures served by low-floor
+// vehicles, a database can bring its own under "low_floor"
+var (
+	lowFloorMarkers = "n"
+	// Set when the database is loaded, while the interface reads departures
+	lowFloorMarkersMutex sync.RWMutex
+)
+
+func SetLowFloorMarkers(markers string) {
+	lowFloorMarkersMutex.Lock()
+	defer lowFloorMarkersMutex.Unlock()
+	lowFloorMarkers = markers
+}
+
+func LowFloorMarkers() string {
+	lowFloorMarkersMutex.RLock()
+	defer lowFloorMarkersMutex.RUnlock()
+	return lowFloorMarkers
+}
+
+func IsLowFloor(annotation string) bool {
+	markers := LowFloorMarkers()
+	return len(markers) != 0 && strings.ContainsAny(annotation, markers)
+}
+
+func (dep Departure) IsLowFloor() bool {
+	return IsLowFloor(dep.Annotation)
+}
+
+func (dep Departure) ServiceMinute() int {
+	hour := dep.Hour
+	if hour < ServiceDayStartHour {
+		hour += 24
+	}
+	if dep.Following {
+		hour += 24
+	}
+
+	return hour * 60 + dep.Minute
+}
+
+// Splits "17ab" into the minute and the annotation letters
+func ParseMinute(str string) (minute int, annotation string, ok bool) {
+	digits := strings.TrimFunc(str, func(r rune) bool {
+		return unicode.IsLetter(r)
+	})
+	if len(digits) == 0 {
+		return 0, "", false
+	}
+
+	annotation = strings.Replace(str, digits, "", 1)
+	return IntOrPanic(digits), annotation, true
+}
+
+func ParseDepartures(hours, mins []string) (result []Departure) {
+	for hi := 0; hi < len(hours) && hi < len(mins); hi++ {
+		hour := IntOrPanic(hours[hi])
+		for mi, str := range strings.Split(mins[hi], " ") {
+			minute, annotation, ok := ParseMinute(str)
+			if !ok {
+				continue
+			}
+
+			result = append(result, Departure {
+				HourIndex: hi,
+				MinIndex: mi,
+				Hour: hour,
+				Minute: minute,
+				Annotation: annotation,
+			})
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].ServiceMinute() < result[j].ServiceMinute()
+	})
+
+	return
+}
+
+type TripCall struct {
+	StopId int
+	Departure Departure
+}
+
+// One vehicle going through the stops of its route
+type Trip struct {
+	Id int
+	LineNr int
+	Direction string
+	Day DayType
+	Calls []TripCall
+	// The vehicle is marked as low-floor at any of its stops
+	LowFloor bool
+}
+
+type TripKey struct {
+	Day DayType
+	HourIndex, MinIndex int
+}
+
+func (trip *Trip) CallAt(stopId int) (TripCall, bool) {
+	for _, call := range trip.Calls {
+		if call.StopId == stopId {
+			return call, true
+		}
+	}
+
+	return TripCall{}, false
+}
+
+// The departure as a moment on the service day that `day` belongs to, or on
+// the one after it for a following departure
+func (dep Departure) Time(day time.Time) time.Time {
+	t := DepartureTime(day, dep.Hour, dep.Minute)
+	if dep.Hour < ServiceDayStartHour && day.Hour() >= ServiceDayStartHour {
+		t = DepartureTime(day.AddDate(0, 0, 1), dep.Hour, dep.Minute)
+	}
+
+	// NOTE(radomski): In the small hours the following day has already begun
+	// on the calendar, only its own night is on the next date
+	if dep.Following && (day.Hour() >= ServiceDayStartHour || dep.Hour < ServiceDayStartHour) {
+		t = DepartureTime(t.AddDate(0, 0, 1), dep.Hour, dep.Minute)
+	}
+
+	return t
+}
+
+func DepartureAtServiceMinute(minute int) Departure {
+	return Departure {
+		Hour: (minute / 60) % 24,
+		Minute: minute % 60,
+		Following: minute >= (24 + ServiceDayStartHour) * 60,
+	}
+}
+
+func (call TripCall) Time(day time.Time) time.Time {
+	return call.Departure.Time(day)
+}
+
+// Minutes between the trip leaving `fromId` and reaching `toId`
+func (trip *Trip) RideLength(day time.Time, fromId, toId int) (int, bool) {
+	from, ok := trip.CallAt(fromId)
+	if !ok {
+		return 0, false
+	}
+
+	to, ok := trip.CallAt(toId)
+	if !ok || to.Departure.ServiceMinute() < from.Departure.ServiceMinute() {
+		return 0, false
+	}
+
+	return MinutesBetween(from.Time(day), to.Time(day)), true
+}
+
+func (stop Stop) TripAt(day DayType, hi, mi int) *Trip {
+	return stop.Trips[TripKey { Day: day, HourIndex: hi, MinIndex: mi }]
+}
+
+type TripIssueKind int
+
+const (
+	// The departure has no continuation, but the route goes on
+	TripEndsEarly TripIssueKind = iota
+	// The departure has no predecessor, but it's not the first stop of the route
+	TripStartsLate
+	// More than one departure at the next stop fits equally well
+	TripAmbiguous
+)
+
+func (kind TripIssueKind) String() string {
+	switch kind {
+	case TripEndsEarly:
+		return "ends early"
+	case TripStartsLate:
+		return "starts late"
+	default:
+		return "ambiguous"
+	}
+}
+
+type TripIssue struct {
+	Kind TripIssueKind
+	// Where it happened, carried along as the ids of the stops needn't be
+	// their indexes in the database
+	Stop Stop
+	Day DayType
+	Departure Departure
+}
+
+type TripReport struct {
+	Trips int
+	Issues []TripIssue
+}
+
+func (report TripReport) Count(kind TripIssueKind) (result int) {
+	for _, issue := range report.Issues {
+		if issue.Kind == kind {
+			result++
+		}
+	}
+
+	return
+}
+
+// Typical time it takes to get from one stop to the next, the median of the
+// gaps between each departure and the first one not earlier at the next stop
+func ExpectedTravel(from, to []Departure) int {
+	var gaps []int
+
+	j := 0
+	for _, dep := range from {
+		for j < len(to) && to[j].ServiceMinute() < dep.ServiceMinute() {
+			j++
+		}
+		if j == len(to) {
+			break
+		}
+
+		gaps = append(gaps, to[j].ServiceMinute() - dep.ServiceMinute())
+	}
+
+	if len(gaps) == 0 {
+		return 0
+	}
+
+	sort.Ints(gaps)
+	return gaps[len(gaps) / 2]
+}
+
+// For every departure in `from` finds the index of the same vehicle in `to`,
+// or -1. Vehicles of one kind don't overtake each other, so the matches of
+// departures with the same annotation keep their order, while an express
+// marked by another letter may pass a slower one. Inside of the allowed window
+// the same annotation is preferred and then the gap closest to the typical one.
+func MatchDepartures(from, to []Departure) (matches []int, ambiguous []bool) {
+	travel := ExpectedTravel(from, to)
+	slack := Max(3, travel / 2)
+
+	matches = make([]int, len(from))
+	ambiguous = make([]bool, len(from))
+	used := make([]bool, len(to))
+
+	// The last match of the departures with each annotation
+	last := make(map[string]int)
+	for i, dep := range from {
+		matches[i] = -1
+		target := dep.ServiceMinute() + travel
+
+		start, present := last[dep.Annotation]
+		if !present {
+			start = -1
+		}
+
+		bestScore := -1
+		for j := start + 1; j < len(to); j++ {
+			gap := to[j].ServiceMinute() - dep.ServiceMinute()
+			if used[j] || gap < 0 {
+				continue
+			}
+
+			dist := to[j].ServiceMinute() - target
+			if dist > slack {
+				break
+			} else if dist < -slack {
+				continue
+			} else if dist < 0 {
+				dist = -dist
+			}
+
+			score := dist
+			if to[j].Annotation != dep.Annotation {
+				score += slack + 1
+			}
+
+			if bestScore == -1 || score < bestScore {
+				bestScore = score
+				matches[i] = j
+				ambiguous[i] = false
+			} else if score == bestScore {
+				ambiguous[i] = true
+			}
+		}
+
+		if matches[i] != -1 {
+			last[dep.Annotation] = matches[i]
+			used[matches[i]] = true
+		}
+	}
+
+	return
+}
+
+// Links the departures of consecutive stops on each route into trips and
+// remembers on every stop which trip each of its departures belongs to
+func ReconstructTrips(stops []Stop) (trips []Trip, report TripReport) {
+	type link struct {
+		stop int
+		key TripKey
+		trip int
+	}
+	var links []link
+
+	for i := range stops {
+		stops[i].Trips = make(map[TripKey]*Trip)
+	}
+
+	for begin := 0; begin < len(stops); {
+		end := begin + 1
+		for end < len(stops) &&
+			stops[end].LineNr == stops[begin].LineNr &&
+			stops[end].Direction == stops[begin].Direction {
+			end++
+		}
+
+		for day := WorkDay; day < DayTypeCount; day++ {
+			var previous []Departure
+			var previousTrips []int
+
+			for k := begin; k < end; k++ {
+				current := ParseDepartures(stops[k].Times.Hours, stops[k].Times.Mins(day))
+				currentTrips := make([]int, len(current))
+				for i := range currentTrips {
+					currentTrips[i] = -1
+				}
+
+				matches, ambiguous := MatchDepartures(previous, current)
+				for i, j := range matches {
+					if ambiguous[i] {
+						report.Issues = append(report.Issues, TripIssue {
+							Kind: TripAmbiguous,
+							Stop: stops[k - 1],
+							Day: day,
+							Departure: previous[i],
+						})
+					}
+
+					if j == -1 {
+						report.Issues = append(report.Issues, TripIssue {
+							Kind: TripEndsEarly,
+							Stop: stops[k - 1],
+							Day: day,
+							Departure: previous[i],
+						})
+						continue
+					}
+
+					currentTrips[j] = previousTrips[i]
+				}
+
+				for j, dep := range current {
+					if currentTrips[j] == -1 {
+						if k != begin {
+							report.Issues = append(report.Issues, TripIssue {
+								Kind: TripStartsLate,
+								Stop: stops[k],
+								Day: day,
+								Departure: dep,
+							})
+						}
+
+						trips = append(trips, Trip {
+							Id: len(trips),
+							LineNr: stops[k].LineNr,
+							Direction: stops[k].Direction,
+							Day: day,
+						})
+						currentTrips[j] = len(trips) - 1
+					}
+
+					trip := &trips[currentTrips[j]]
+					trip.Calls = append(trip.Calls, TripCall {
+						StopId: stops[k].Id,
+						Departure: dep,
+					})
+					trip.LowFloor = trip.LowFloor || dep.IsLowFloor()
+					links = append(links, link {
+						stop: k,
+						key: TripKey { Day: day, HourIndex: dep.HourIndex, MinIndex: dep.MinIndex },
+						trip: currentTrips[j],
+					})
+				}
+
+				previous = current
+				previousTrips = currentTrips
+			}
+		}
+
+		begin = end
+	}
+
+	// NOTE(radomski): Pointers are taken only now, when `trips` won't grow anymore
+	for _, l := range links {
+		stops[l.stop].Trips[l.key] = &trips[l.trip]
+	}
+
+	report.Trips = len(trips)
+	return
+}
@@ -0,0 +1,144 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+)
+
+// Line 1 through A, B and C, with ids that aren't their indexes. Counted from
+// A the trips are:
+//   0: 8:00, 8:04, 8:08
+//   1: 8:10, 8:16, 8:20, the slow one overtaken by the express
+//   2: 8:12e, 8:14e, 8:18e
+//   3: 8:20, 8:24, turning back at B
+//   4: 8:30, 8:34, 8:38
+//   5: 8:50, and then either 8:52 or 8:56 at B, 8:52 is taken
+//   6: starting at B, 8:40, 8:44
+//   7: starting at B, the other of 8:52 and 8:56, 9:00
+func testTripStops() []Stop {
+	stop := func(id int, name string, hours, mins []string) Stop {
+		return Stop {
+			Id: id,
+			LineNr: 1,
+			Direction: "C",
+			Name: name,
+			Times: Times { Hours: hours, WorkMins: mins },
+		}
+	}
+
+	return []Stop {
+		stop(10, "A", []string { "8" }, []string { "00 10 12e 20 30 50" }),
+		stop(11, "B", []string { "8" }, []string { "04 14e 16 24 34 40 52 56" }),
+		stop(12, "C", []string { "8", "9" }, []string { "08 18e 20 38 44 56", "00" }),
+	}
+}
+
+func TestReconstructTrips(t *testing.T) {
+	stops := testTripStops()
+	trips, report := ReconstructTrips(stops)
+	if report.Trips != 8 || len(trips) != 8 {
+		t.Fatalf("Expected 8 trips, got %d in the report and %d", report.Trips, len(trips))
+	}
+
+	// The trip of every departure, in the order of the minutes
+	expected := [][]int {
+		{ 0, 1, 2, 3, 4, 5 },
+		{ 0, 2, 1, 3, 4, 6, 5, 7 },
+		{ 0, 2, 1, 4, 6, 5, 7 },
+	}
+	for s, stop := range stops {
+		for i, want := range expected[s] {
+			hi, mi := 0, i
+			if s == 2 && i == 6 {
+				hi, mi = 1, 0
+			}
+
+			trip := stop.TripAt(WorkDay, hi, mi)
+			if trip == nil || trip.Id != want {
+				t.Errorf("%s, departure %d: expected trip %d, got %+v", stop.Name, i, want, trip)
+			}
+		}
+	}
+
+	day := time.Date(2020, 6, 1, 7, 0, 0, 0, time.UTC)
+	rides := []struct {
+		trip int
+		from, to int
+		length int
+		ok bool
+	}{
+		{ 0, 10, 12, 8, true },
+		// Guessing by the first departure not earlier would take the express
+		{ 1, 10, 12, 10, true },
+		{ 2, 10, 12, 6, true },
+		{ 3, 10, 11, 4, true },
+		{ 3, 10, 12, 0, false },
+		{ 6, 11, 12, 4, true },
+		{ 6, 10, 12, 0, false },
+		{ 7, 11, 12, 4, true },
+	}
+	for _, ride := range rides {
+		length, ok := trips[ride.trip].RideLength(day, ride.from, ride.to)
+		if length != ride.length || ok != ride.ok {
+			t.Errorf("Trip %d from %d to %d: expected %d, %v, got %d, %v",
+				ride.trip, ride.from, ride.to, ride.length, ride.ok, length, ok)
+		}
+	}
+
+	issues := []struct {
+		kind TripIssueKind
+		stop string
+		hour, minute int
+	}{
+		{ TripAmbiguous, "A", 8, 50 },
+		{ TripStartsLate, "B", 8, 40 },
+		{ TripStartsLate, "B", 8, 56 },
+		{ TripEndsEarly, "B", 8, 24 },
+	}
+	if len(report.Issues) != len(issues) {
+		t.Fatalf("Expected %d issues, got %+v", len(issues), report.Issues)
+	}
+	for i, want := range issues {
+		got := report.Issues[i]
+		if got.Kind != want.kind || got.Stop.Name != want.stop || got.Day != WorkDay ||
+			got.Departure.Hour != want.hour || got.Departure.Minute != want.minute {
+			t.Errorf("Issue %d: expected %s at %s %d:%02d, got %s at %s (id %d) %d:%02d", i,
+				want.kind, want.stop, want.hour, want.minute,
+				got.Kind, got.Stop.Name, got.Stop.Id, got.Departure.Hour, got.Departure.Minute)
+		}
+	}
+
+	if report.Count(TripStartsLate) != 2 || report.Count(TripEndsEarly) != 1 || report.Count(TripAmbiguous) != 1 {
+		t.Errorf("Expected 2 late starts, 1 early end and 1 ambiguous, got %d, %d and %d",
+			report.Count(TripStartsLate), report.Count(TripEndsEarly), report.Count(TripAmbiguous))
+	}
+}
+
+func TestMatchDepartures(t *testing.T) {
+	parse := func(mins string) []Departure {
+		return ParseDepartures([]string { "8" }, []string { mins })
+	}
+
+	tests := []struct {
+		name string
+		from, to string
+		matches []int
+		ambiguous []bool
+	}{
+		{ "steady", "00 10 20", "04 14 24", []int { 0, 1, 2 }, []bool { false, false, false } },
+		{ "the same annotation first", "00", "03e 05", []int { 1 }, []bool { false } },
+		{ "overtaken by an express", "00 02e", "04e 06", []int { 1, 0 }, []bool { false, false } },
+		{ "too far", "00 10 20", "04 14 50", []int { 0, 1, -1 }, []bool { false, false, false } },
+		{ "ambiguous", "00 10 20 30", "04 14 22 26 34", []int { 0, 1, 2, 4 }, []bool { false, false, true, false } },
+	}
+
+	for _, test := range tests {
+		matches, ambiguous := MatchDepartures(parse(test.from), parse(test.to))
+		for i := range test.matches {
+			if matches[i] != test.matches[i] || ambiguous[i] != test.ambiguous[i] {
+				t.Errorf("%s: expected %v, %v, got %v, %v", test.name, test.matches, test.ambiguous, matches, ambiguous)
+				break
+			}
+		}
+	}
+}